@@ -1,11 +1,19 @@
 package main
 
-import "log"
+import (
+	"log"
+	"unicode"
+)
 
 type Action func(ev Event, keymap *map[Event][]Action)
 
+// HandleAction runs the actions bound to ev. A rune that is not bound runs
+// those bound to Rune.AsEvent(), if any, so that a keymap can take any text.
 func HandleAction(ev Event, keymap *map[Event][]Action) {
 	actions, ok := (*keymap)[ev.Comparable()]
+	if !ok && ev.Type == Rune {
+		actions, ok = (*keymap)[Rune.AsEvent()]
+	}
 	if ok {
 		for _, action := range actions {
 			action(ev, keymap)
@@ -14,3 +22,50 @@ func HandleAction(ev Event, keymap *map[Event][]Action) {
 		log.Printf("unknown event: %+v\n", ev)
 	}
 }
+
+// KeyReader reads the next key typed, in the mode "key", instead of looking
+// it up in the keymap of the mode it was read from.
+type KeyReader struct {
+	modes *Modes
+	fn    func(r rune)
+}
+
+func NewKeyReader(c *Controller, modes *Modes) *KeyReader {
+	k := &KeyReader{modes: modes}
+
+	cmds := map[string]CMD{
+		"resize": c.Resize,
+		"cancel": k.Cancel,
+	}
+	keymap := make(map[Event][]Action)
+	keymap[Resize.AsEvent()] = []Action{ToAction(cmds["resize"], nil)}
+	keymap[Rune.AsEvent()] = []Action{k.read}
+	bindings := NewBindings(c, keymap, cmds)
+	if err := bindings.Parse("ctrl-l:resize,esc:cancel,ctrl-c:cancel"); err != nil {
+		log.Fatalf("%+v", err)
+	}
+	modes.Add("key", bindings)
+	return k
+}
+
+// Read passes the next key typed to fn if it is an ASCII letter or digit.
+// Other keys are ignored, esc cancels.
+func (k *KeyReader) Read(fn func(r rune)) {
+	k.fn = fn
+	k.modes.Enter("key")
+}
+
+func (k *KeyReader) Cancel() {
+	k.fn = nil
+	k.modes.Leave()
+}
+
+func (k *KeyReader) read(ev Event, _ *map[Event][]Action) {
+	r := ev.Char
+	if r > '~' || !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+		return
+	}
+	fn := k.fn
+	k.Cancel()
+	fn(r)
+}
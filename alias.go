@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// Aliases running aliases deeper than this are taken to be recursive
+	aliasMaxDepth = 16
+)
+
+// Aliases are commands defined by the user, each standing for a script of
+// other commands. They are added to the command table, so they can be used
+// wherever builtin commands can.
+type Aliases struct {
+	c       *Controller
+	cmds    map[string]CMD
+	scripts map[string]string
+	depth   int
+}
+
+func NewAliases(c *Controller, cmds map[string]CMD) *Aliases {
+	return &Aliases{
+		c:       c,
+		cmds:    cmds,
+		scripts: make(map[string]string),
+	}
+}
+
+// Define makes name run script. Arguments given to it are appended to the
+// last command of the script.
+func (a *Aliases) Define(name, script string) error {
+	if name == "" || strings.ContainsAny(name, " \t;'\"\\$%") {
+		return fmt.Errorf("invalid alias name '%s'", name)
+	}
+	if _, ok := a.scripts[name]; !ok {
+		if _, ok := a.cmds[name]; ok {
+			return fmt.Errorf("'%s' is a builtin command", name)
+		}
+	}
+
+	parsed, err := ParseScript(script)
+	if err != nil {
+		return err
+	}
+	for _, command := range parsed {
+		if command.Name == name {
+			return fmt.Errorf("alias '%s' runs itself", name)
+		}
+	}
+	if err := parsed.Check(a.cmds); err != nil {
+		return err
+	}
+
+	a.scripts[name] = script
+	a.cmds[name] = func(ev Event, keymap *map[Event][]Action, args []string) {
+		a.run(name, parsed, ev, keymap, args)
+	}
+	return nil
+}
+
+func (a *Aliases) run(name string, script Script, ev Event, keymap *map[Event][]Action, args []string) {
+	if a.depth >= aliasMaxDepth {
+		a.c.Warn("Alias '%s' nested too deep", name)
+		return
+	}
+	a.depth++
+	defer func() { a.depth-- }()
+
+	if len(args) != 0 {
+		last := script[len(script)-1]
+		last.Args = append(last.Args[:len(last.Args):len(last.Args)], literalWords(args)...)
+		script = append(script[:len(script)-1:len(script)-1], last)
+	}
+
+	// Aliases may have been redefined since, or gone
+	action, err := script.Compile(a.cmds, a.c)
+	if err != nil {
+		a.c.Warn("Alias '%s': %v", name, err)
+		return
+	}
+	action(ev, keymap)
+}
+
+func (a *Aliases) Undefine(name string) error {
+	if _, ok := a.scripts[name]; !ok {
+		return fmt.Errorf("no alias '%s'", name)
+	}
+	delete(a.scripts, name)
+	delete(a.cmds, name)
+	return nil
+}
+
+func (a *Aliases) Names() []string {
+	names := make([]string, 0, len(a.scripts))
+	for name := range a.scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// literalWords turns expanded arguments back into words, so that they are
+// not expanded again.
+func literalWords(args []string) []Word {
+	words := make([]Word, 0, len(args))
+	for _, arg := range args {
+		words = append(words, Word{{text: arg, quoted: true}})
+	}
+	return words
+}
+
+// AliasCMD is "alias <name> <commands>", the commands joined by
+// joinScript.
+func (a *Aliases) AliasCMD(args []string) {
+	if len(args) < 2 {
+		a.c.Warn("Usage: alias <name> <commands>")
+		return
+	}
+
+	script := joinScript(args[1:])
+	if err := a.Define(args[0], script); err != nil {
+		a.c.Warn("%v", err)
+	}
+}
+
+func (a *Aliases) UnaliasCMD(args []string) {
+	if len(args) != 1 {
+		a.c.Warn("Usage: unalias <name>")
+		return
+	}
+	if err := a.Undefine(args[0]); err != nil {
+		a.c.Warn("%v", err)
+	}
+}
+
+// ListCMD lists all commands, the script of aliases next to them. Entering
+// one runs it.
+func (a *Aliases) ListCMD(menu *Menu) CMD {
+	return func(ev Event, keymap *map[Event][]Action, args []string) {
+		names := make([]string, 0, len(a.cmds))
+		for name := range a.cmds {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		items := make([]MenuItem, 0, len(names))
+		for _, name := range names {
+			info := "builtin"
+			if script, ok := a.scripts[name]; ok {
+				info = script
+			}
+			items = append(items, MenuItem{Text: name, Info: info, Value: name})
+		}
+
+		menu.Open("Commands", items, func(item MenuItem) {
+			if cmd, ok := a.cmds[item.Value]; ok {
+				ToAction(cmd, nil)(ev, keymap)
+			}
+		}, func(item MenuItem) error {
+			return a.Undefine(item.Value)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAliasDefine(t *testing.T) {
+	cmds := map[string]CMD{
+		"next": func() {},
+		"dir":  func([]string) {},
+	}
+	a := NewAliases(nil, cmds)
+
+	tests := []struct {
+		name   string
+		script string
+		ok     bool
+	}{
+		{"n", "next; next", true},
+		{"s", "dir sort_by_size", true},
+		// Aliases can use aliases
+		{"nn", "n; n", true},
+		{"n", "next", true},
+		{"", "next", false},
+		{"a b", "next", false},
+		{"next", "next", false},
+		{"x", "x", false},
+		{"x", "bogus", false},
+		{"x", "dir bogus", false},
+		{"x", "next 'a", false},
+	}
+	for _, test := range tests {
+		if err := a.Define(test.name, test.script); (err == nil) != test.ok {
+			t.Errorf("Define(%q, %q) = %v", test.name, test.script, err)
+		}
+	}
+	if got, want := a.Names(), []string{"n", "nn", "s"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %q, want %q", got, want)
+	}
+
+	if err := a.Undefine("n"); err != nil {
+		t.Error(err)
+	}
+	if err := a.Undefine("next"); err == nil {
+		t.Error("undefined a builtin command")
+	}
+	if _, ok := cmds["n"]; ok {
+		t.Error("n is still a command")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pfrc")
+	lines := []string{
+		"# comment",
+		"",
+		"next",
+		"bogus",
+		"dir bogus",
+		"  next; next  ",
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	cmds := map[string]CMD{
+		"next": func() { n++ },
+		"dir":  func([]string) { t.Error("dir ran") },
+	}
+	keymap := make(map[Event][]Action)
+	errs := LoadConfig(path, cmds, testVars{}, &keymap)
+	if n != 3 {
+		t.Errorf("ran next %d times, want 3", n)
+	}
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), ":4:") || !strings.Contains(errs[1].Error(), ":5:") {
+		t.Errorf("errors %v, want lines 4 and 5", errs)
+	}
+
+	if errs := LoadConfig(path+".missing", cmds, testVars{}, &keymap); errs != nil {
+		t.Errorf("a missing file gave %v", errs)
+	}
+}
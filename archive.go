@@ -0,0 +1,648 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// Links inside archives are followed at most this many times in a path
+	archiveMaxLinks = 40
+)
+
+var archiveExts = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+func isArchiveName(name string) bool {
+	lname := strings.ToLower(name)
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(lname, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// isArchive reports whether info is an archive that can be browsed as a
+// directory.
+func isArchive(info *FileInfo) bool {
+	return info.Mode().IsRegular() && isArchiveName(info.Name())
+}
+
+// splitArchivePath splits path into an archive file on disk and the slash
+// separated path of a member inside it, which is empty for the archive
+// itself. ok is false if path does not go through an archive. Archives
+// already loaded are known without going to the disk.
+func splitArchivePath(p string) (archive, member string, ok bool) {
+	for i := 1; i <= len(p); i++ {
+		if i < len(p) && p[i] != filepath.Separator {
+			continue
+		}
+		prefix := p[:i]
+		if !isArchiveName(prefix) {
+			continue
+		}
+		archiveCache.Lock()
+		_, cached := archiveCache.m[prefix]
+		archiveCache.Unlock()
+		if cached {
+			member = strings.TrimPrefix(p[i:], string(filepath.Separator))
+			return prefix, filepath.ToSlash(member), true
+		}
+		if fi, err := os.Stat(prefix); err == nil && fi.Mode().IsRegular() {
+			member = strings.TrimPrefix(p[i:], string(filepath.Separator))
+			return prefix, filepath.ToSlash(member), true
+		}
+	}
+	return "", "", false
+}
+
+type archiveHeader struct {
+	Name string // cleaned, slash separated
+	Info fs.FileInfo
+	Link string
+	// Open returns the contents of the member. It is only valid until the
+	// walk function returns.
+	Open func() (io.Reader, error)
+}
+
+var errStopWalk = errors.New("stop walk")
+
+// walkArchive calls fn for every member of the archive at p, in archive
+// order. Returning errStopWalk from fn ends the walk without an error.
+func walkArchive(p string, fn func(h archiveHeader) error) error {
+	var err error
+	if strings.HasSuffix(strings.ToLower(p), ".zip") {
+		err = walkZip(p, fn)
+	} else {
+		err = walkTar(p, fn)
+	}
+	if err == errStopWalk {
+		return nil
+	}
+	return err
+}
+
+func cleanMemberName(name string) string {
+	// Rooting the name first drops any leading ".."
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+func walkZip(p string, fn func(h archiveHeader) error) error {
+	r, err := zip.OpenReader(p)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		var rc io.ReadCloser
+		h := archiveHeader{
+			Name: cleanMemberName(f.Name),
+			Info: f.FileInfo(),
+			Open: func() (io.Reader, error) {
+				var err error
+				rc, err = f.Open()
+				return rc, err
+			},
+		}
+		if h.Info.Mode()&fs.ModeSymlink != 0 {
+			// Zip stores the link target as the contents
+			if r, err := h.Open(); err == nil {
+				if b, err := io.ReadAll(io.LimitReader(r, 4096)); err == nil {
+					h.Link = string(b)
+				}
+				rc.Close()
+				rc = nil
+			}
+		}
+
+		err := fn(h)
+		if rc != nil {
+			rc.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkTar(p string, fn func(h archiveHeader) error) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if lp := strings.ToLower(p); strings.HasSuffix(lp, ".gz") || strings.HasSuffix(lp, ".tgz") {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		h := archiveHeader{
+			Name: cleanMemberName(hdr.Name),
+			Info: hdr.FileInfo(),
+			Open: func() (io.Reader, error) { return tr, nil },
+		}
+		if hdr.Typeflag == tar.TypeSymlink {
+			h.Link = hdr.Linkname
+		}
+		if err := fn(h); err != nil {
+			return err
+		}
+	}
+}
+
+// archiveDirInfo describes directories that are only implied by the paths
+// of archive members.
+type archiveDirInfo struct {
+	name    string
+	modTime time.Time
+}
+
+func (i archiveDirInfo) Name() string       { return i.name }
+func (i archiveDirInfo) Size() int64        { return 0 }
+func (i archiveDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0755 }
+func (i archiveDirInfo) ModTime() time.Time { return i.modTime }
+func (i archiveDirInfo) IsDir() bool        { return true }
+func (i archiveDirInfo) Sys() any           { return nil }
+
+type archiveEntry struct {
+	info fs.FileInfo
+	link string
+}
+
+type archiveIndex struct {
+	path     string
+	modTime  time.Time
+	size     int64
+	entries  map[string]archiveEntry
+	children map[string][]string
+}
+
+var archiveCache = struct {
+	sync.Mutex
+	m map[string]*archiveIndex
+}{m: make(map[string]*archiveIndex)}
+
+// loadArchive reads the member list of an archive, or returns the cached
+// one if the archive has not changed since. An archive gone is dropped
+// from the cache, so that splitArchivePath no longer finds it there.
+func loadArchive(p string) (*archiveIndex, error) {
+	fi, err := os.Stat(p)
+	if err == nil && !fi.Mode().IsRegular() {
+		err = &fs.PathError{Op: "open", Path: p, Err: syscall.EISDIR}
+	}
+	if err != nil {
+		archiveCache.Lock()
+		delete(archiveCache.m, p)
+		archiveCache.Unlock()
+		return nil, err
+	}
+
+	archiveCache.Lock()
+	idx, ok := archiveCache.m[p]
+	archiveCache.Unlock()
+	if ok && idx.modTime.Equal(fi.ModTime()) && idx.size == fi.Size() {
+		return idx, nil
+	}
+
+	idx = &archiveIndex{
+		path:     p,
+		modTime:  fi.ModTime(),
+		size:     fi.Size(),
+		entries:  make(map[string]archiveEntry),
+		children: make(map[string][]string),
+	}
+	idx.entries[""] = archiveEntry{info: archiveDirInfo{filepath.Base(p), fi.ModTime()}}
+
+	var add func(name string, e archiveEntry)
+	add = func(name string, e archiveEntry) {
+		if _, ok := idx.entries[name]; ok {
+			if e.info.IsDir() {
+				// Explicit entries have better metadata
+				idx.entries[name] = e
+			}
+			return
+		}
+		parent := path.Dir(name)
+		if parent == "." {
+			parent = ""
+		}
+		if _, ok := idx.entries[parent]; !ok {
+			add(parent, archiveEntry{info: archiveDirInfo{path.Base(parent), fi.ModTime()}})
+		}
+		idx.entries[name] = e
+		idx.children[parent] = append(idx.children[parent], name)
+	}
+
+	err = walkArchive(p, func(h archiveHeader) error {
+		if h.Name != "" {
+			add(h.Name, archiveEntry{info: h.Info, link: h.Link})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	archiveCache.Lock()
+	archiveCache.m[p] = idx
+	archiveCache.Unlock()
+	return idx, nil
+}
+
+// resolve returns the member that member stands for, following links in
+// the middle of it, and at its end if follow is set. Links can only point
+// to other members, those that are absolute or go up out of the archive
+// point to nothing.
+func (idx *archiveIndex) resolve(member string, follow bool) (string, error) {
+	member = cleanMemberName(member)
+	for n := 0; n <= archiveMaxLinks; n++ {
+		if member == "" {
+			return "", nil
+		}
+
+		elems := strings.Split(member, "/")
+		resolved, linked := "", false
+		for i, elem := range elems {
+			name := path.Join(resolved, elem)
+			e, ok := idx.entries[name]
+			if !ok {
+				return "", fs.ErrNotExist
+			}
+
+			last := i == len(elems)-1
+			if e.link != "" && (!last || follow) {
+				if path.IsAbs(e.link) {
+					return "", fs.ErrNotExist
+				}
+				target := path.Join(append([]string{resolved, e.link}, elems[i+1:]...)...)
+				if target == ".." || strings.HasPrefix(target, "../") {
+					return "", fs.ErrNotExist
+				}
+				if target == "." {
+					target = ""
+				}
+				member, linked = target, true
+				break
+			}
+			if !last && !e.info.IsDir() {
+				return "", syscall.ENOTDIR
+			}
+			resolved = name
+		}
+		if !linked {
+			return member, nil
+		}
+	}
+	return "", syscall.ELOOP
+}
+
+// ArchiveFS serves the members of archives on disk, under the path of the
+// archive, e.g. /tmp/a.zip/dir/file. It is read only.
+type ArchiveFS struct{}
+
+// entry returns the index of the archive name is in, and the member it
+// stands for, see archiveIndex.resolve.
+func (ArchiveFS) entry(op, name string, follow bool) (*archiveIndex, string, error) {
+	archive, member, ok := splitArchivePath(name)
+	if !ok {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	idx, err := loadArchive(archive)
+	if err != nil {
+		return nil, "", err
+	}
+	member, err = idx.resolve(member, follow)
+	if err != nil {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return idx, member, nil
+}
+
+type archiveFile struct {
+	*io.PipeReader
+	info fs.FileInfo
+}
+
+func (f archiveFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (a ArchiveFS) Open(name string) (fs.File, error) {
+	idx, member, err := a.entry("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	e := idx.entries[member]
+	if e.info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	}
+
+	// Tar members can only be read in order, so the contents are
+	// streamed from a walk, which is aborted if the file is closed early.
+	pr, pw := io.Pipe()
+	go func() {
+		err := walkArchive(idx.path, func(h archiveHeader) error {
+			if h.Name != member {
+				return nil
+			}
+			r, err := h.Open()
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(pw, r); err != nil {
+				return err
+			}
+			return errStopWalk
+		})
+		pw.CloseWithError(err)
+	}()
+	return archiveFile{pr, e.info}, nil
+}
+
+func (a ArchiveFS) ReadDir(name string) ([]fs.FileInfo, error) {
+	idx, member, err := a.entry("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !idx.entries[member].info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: syscall.ENOTDIR}
+	}
+
+	infos := make([]fs.FileInfo, 0, len(idx.children[member]))
+	for _, child := range idx.children[member] {
+		infos = append(infos, idx.entries[child].info)
+	}
+	return infos, nil
+}
+
+func (a ArchiveFS) Stat(name string) (fs.FileInfo, error) {
+	idx, member, err := a.entry("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return idx.entries[member].info, nil
+}
+
+func (a ArchiveFS) Lstat(name string) (fs.FileInfo, error) {
+	idx, member, err := a.entry("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return idx.entries[member].info, nil
+}
+
+func (a ArchiveFS) Readlink(name string) (string, error) {
+	idx, member, err := a.entry("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	e := idx.entries[member]
+	if e.link == "" {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: syscall.EINVAL}
+	}
+	return e.link, nil
+}
+
+func (ArchiveFS) Attr(info fs.FileInfo) FileAttr {
+	attr := FileAttr{
+		AccessTime: info.ModTime(),
+		ChangeTime: info.ModTime(),
+	}
+	if hdr, ok := info.Sys().(*tar.Header); ok {
+		attr.User = hdr.Uname
+		attr.Group = hdr.Gname
+		if attr.User == "" {
+			attr.Uid = fmt.Sprint(hdr.Uid)
+		}
+		if attr.Group == "" {
+			attr.Gid = fmt.Sprint(hdr.Gid)
+		}
+		if !hdr.AccessTime.IsZero() {
+			attr.AccessTime = hdr.AccessTime
+		}
+		if !hdr.ChangeTime.IsZero() {
+			attr.ChangeTime = hdr.ChangeTime
+		}
+	}
+	return attr
+}
+
+// Chdir only checks that name is a directory, the process stays in the
+// directory of the archive.
+func (a ArchiveFS) Chdir(name string) error {
+	idx, member, err := a.entry("chdir", name, true)
+	if err != nil {
+		return err
+	}
+	if !idx.entries[member].info.IsDir() {
+		return &fs.PathError{Op: "chdir", Path: name, Err: syscall.ENOTDIR}
+	}
+	return nil
+}
+
+// extractPaths extracts the archive members among paths into a new
+// temporary directory and returns paths with those members replaced by
+// their extracted copies. Other paths are returned unchanged. Links that
+// could point out of their archive are not extracted, they are returned
+// in skipped, and left out of the paths.
+func extractPaths(paths []string) (out, skipped []string, err error) {
+	out = make([]string, len(paths))
+	copy(out, paths)
+
+	// One pass over every archive, whatever the number of members picked
+	byArchive := make(map[string][]int)
+	for i, p := range paths {
+		if archive, member, ok := splitArchivePath(p); ok && member != "" {
+			byArchive[archive] = append(byArchive[archive], i)
+		}
+	}
+	if len(byArchive) == 0 {
+		return out, nil, nil
+	}
+
+	tmp, err := os.MkdirTemp("", "pf-")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	archives := make([]string, 0, len(byArchive))
+	for archive := range byArchive {
+		archives = append(archives, archive)
+	}
+	sort.Strings(archives)
+
+	dropped := make(map[int]bool)
+	for i, archive := range archives {
+		idx, err := loadArchive(archive)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		dest := filepath.Join(tmp, fmt.Sprint(i))
+		members := make(map[string]struct{})
+		picks := make(map[int]string)
+		for _, j := range byArchive[archive] {
+			_, member, _ := splitArchivePath(paths[j])
+			// The member may have been picked through a link to a
+			// directory
+			member, err := idx.resolve(member, false)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", paths[j], err)
+			}
+			members[member] = struct{}{}
+			picks[j] = member
+			out[j] = filepath.Join(dest, filepath.FromSlash(member))
+		}
+
+		skippedMembers, err := extractArchive(archive, members, dest)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, member := range skippedMembers {
+			skipped = append(skipped, filepath.Join(archive, filepath.FromSlash(member)))
+		}
+		for j, member := range picks {
+			if containsString(skippedMembers, member) {
+				dropped[j] = true
+			}
+		}
+	}
+
+	kept := out[:0]
+	for j, p := range out {
+		if !dropped[j] {
+			kept = append(kept, p)
+		}
+	}
+	return kept, skipped, nil
+}
+
+// extractArchive extracts members, and everything below those that are
+// directories, to dest. It returns the links it skipped, see localLink.
+func extractArchive(archive string, members map[string]struct{}, dest string) (skipped []string, err error) {
+	picked := func(name string) bool {
+		for name != "." && name != "" {
+			if _, ok := members[name]; ok {
+				return true
+			}
+			name = path.Dir(name)
+		}
+		return false
+	}
+
+	err = walkArchive(archive, func(h archiveHeader) error {
+		if h.Name == "" || !picked(h.Name) {
+			return nil
+		}
+
+		target, err := extractTarget(dest, h.Name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		mode := h.Info.Mode()
+		switch {
+		case mode.IsDir():
+			return os.MkdirAll(target, mode.Perm()|0700)
+		case mode&fs.ModeSymlink != 0:
+			if !localLink(h.Link) {
+				// It could point anywhere, and later members be
+				// written through it
+				skipped = append(skipped, h.Name)
+				return nil
+			}
+			return os.Symlink(h.Link, target)
+		case mode.IsRegular():
+			r, err := h.Open()
+			if err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, r)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			return err
+		}
+		return nil
+	})
+	return skipped, err
+}
+
+// extractTarget returns where to extract the member name to in dest. It
+// must stay inside dest, and not go through symlinks extracted before.
+func extractTarget(dest, name string) (string, error) {
+	target := filepath.Join(dest, filepath.FromSlash(name))
+	rel, err := filepath.Rel(dest, target)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("member %s is outside the archive", name)
+	}
+
+	p := dest
+	for _, elem := range strings.Split(rel, string(filepath.Separator)) {
+		p = filepath.Join(p, elem)
+		fi, err := os.Lstat(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("member %s is through a symlink", name)
+		}
+	}
+	return target, nil
+}
+
+// localLink reports whether a symlink to link stays where it is, that is
+// link is relative and does not go up.
+func localLink(link string) bool {
+	if link == "" || path.IsAbs(link) || filepath.IsAbs(link) {
+		return false
+	}
+	for _, elem := range strings.Split(filepath.ToSlash(link), "/") {
+		if elem == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"archive/tar"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+// writeTestTar writes a tar in dir with members:
+//
+//	real/f       "hi"
+//	linkdir  ->  real
+//	up       ->  ../x
+//	abs      ->  /etc
+//	loop1    ->  loop2
+//	loop2    ->  loop1
+func writeTestTar(t *testing.T, dir string) string {
+	t.Helper()
+	name := filepath.Join(dir, "t.tar")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := tar.NewWriter(f)
+	headers := []*tar.Header{
+		{Name: "real/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "real/f", Typeflag: tar.TypeReg, Mode: 0644, Size: 2},
+		{Name: "linkdir", Typeflag: tar.TypeSymlink, Linkname: "real"},
+		{Name: "up", Typeflag: tar.TypeSymlink, Linkname: "../x"},
+		{Name: "abs", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+		{Name: "loop1", Typeflag: tar.TypeSymlink, Linkname: "loop2"},
+		{Name: "loop2", Typeflag: tar.TypeSymlink, Linkname: "loop1"},
+	}
+	for _, hdr := range headers {
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size != 0 {
+			if _, err := w.Write([]byte("hi")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestArchiveResolve(t *testing.T) {
+	idx, err := loadArchive(writeTestTar(t, t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		member string
+		follow bool
+		want   string
+		err    error
+	}{
+		{"", true, "", nil},
+		{"real/f", true, "real/f", nil},
+		{"real/", true, "real", nil},
+		{"linkdir", false, "linkdir", nil},
+		{"linkdir", true, "real", nil},
+		{"linkdir/f", false, "real/f", nil},
+		{"real/f/g", true, "", syscall.ENOTDIR},
+		{"missing", true, "", fs.ErrNotExist},
+		{"up", false, "up", nil},
+		{"up", true, "", fs.ErrNotExist},
+		{"abs", true, "", fs.ErrNotExist},
+		{"loop1", true, "", syscall.ELOOP},
+	}
+	for _, test := range tests {
+		got, err := idx.resolve(test.member, test.follow)
+		if got != test.want || !errors.Is(err, test.err) {
+			t.Errorf("resolve(%q, %v) = %q, %v, want %q, %v", test.member, test.follow, got, err, test.want, test.err)
+		}
+	}
+}
+
+func TestSplitArchivePath(t *testing.T) {
+	dir := t.TempDir()
+	archive := writeTestTar(t, dir)
+	if err := os.Mkdir(filepath.Join(dir, "d.zip"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path    string
+		archive string
+		member  string
+		ok      bool
+	}{
+		{archive, archive, "", true},
+		{archive + "/real/f", archive, "real/f", true},
+		{filepath.Join(dir, "d.zip", "x"), "", "", false},
+		{filepath.Join(dir, "other"), "", "", false},
+	}
+	for _, test := range tests {
+		archive, member, ok := splitArchivePath(test.path)
+		if archive != test.archive || member != test.member || ok != test.ok {
+			t.Errorf("splitArchivePath(%q) = %q, %q, %v, want %q, %q, %v", test.path, archive, member, ok, test.archive, test.member, test.ok)
+		}
+	}
+}
+
+func TestExtractPaths(t *testing.T) {
+	archive := writeTestTar(t, t.TempDir())
+
+	paths, skipped, err := extractPaths([]string{"/elsewhere", archive + "/linkdir/f", archive + "/up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{archive + "/up"}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("skipped %v, want %v", skipped, want)
+	}
+	if len(paths) != 2 || paths[0] != "/elsewhere" || filepath.Base(paths[1]) != "f" {
+		t.Fatalf("extracted to %v", paths)
+	}
+	defer os.RemoveAll(filepath.Dir(filepath.Dir(filepath.Dir(paths[1]))))
+	if b, err := os.ReadFile(paths[1]); err != nil || string(b) != "hi" {
+		t.Errorf("extracted %q, %v", b, err)
+	}
+}
+
+func TestExtractTarget(t *testing.T) {
+	dest := t.TempDir()
+	if err := os.Symlink(t.TempDir(), filepath.Join(dest, "l")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"a", true},
+		{"a/b/c", true},
+		{"", false},
+		{"..", false},
+		{"../x", false},
+		{"a/../../x", false},
+		{"l", false},
+		{"l/x", false},
+	}
+	for _, test := range tests {
+		target, err := extractTarget(dest, test.name)
+		if (err == nil) != test.ok {
+			t.Errorf("extractTarget(%q) = %q, %v", test.name, target, err)
+		}
+	}
+}
+
+func TestLocalLink(t *testing.T) {
+	tests := []struct {
+		link string
+		want bool
+	}{
+		{"a", true},
+		{"a/b", true},
+		{"./a", true},
+		{"", false},
+		{"/etc", false},
+		{"..", false},
+		{"../a", false},
+		{"a/../b", false},
+	}
+	for _, test := range tests {
+		if got := localLink(test.link); got != test.want {
+			t.Errorf("localLink(%q) = %v, want %v", test.link, got, test.want)
+		}
+	}
+}
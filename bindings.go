@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+type binding struct {
+	key  string // As written
+	text string // The commands as written
+}
+
+// Bindings is the keymap of a mode, along with how each binding was
+// written, so that bindings can be listed and changed at runtime.
+type Bindings struct {
+	c        *Controller
+	keymap   map[Event][]Action
+	cmds     map[string]CMD
+	bindings map[Event]binding
+}
+
+func NewBindings(c *Controller, keymap map[Event][]Action, cmds map[string]CMD) *Bindings {
+	return &Bindings{
+		c:        c,
+		keymap:   keymap,
+		cmds:     cmds,
+		bindings: make(map[Event]binding),
+	}
+}
+
+// Parse adds the bindings in str, written as for eachBinding.
+func (b *Bindings) Parse(str string) error {
+	return eachBinding(str, func(keys []Event, name string, script Script, text string) error {
+		action, err := script.Compile(b.cmds, b.c)
+		if err != nil {
+			return err
+		}
+		bindKeys(b.keymap, keys, []Action{action})
+		b.bindings[seqEvent(keys)] = binding{key: name, text: text}
+		return nil
+	})
+}
+
+// Map binds key to the commands in script, replacing what it was bound to.
+func (b *Bindings) Map(key, script string) error {
+	keys, err := parseKeys(key)
+	if err != nil {
+		return err
+	}
+	action, err := CompileScript(script, b.cmds, b.c)
+	if err != nil {
+		return err
+	}
+	bindKeys(b.keymap, keys, []Action{action})
+	b.bindings[seqEvent(keys)] = binding{key: key, text: script}
+	return nil
+}
+
+func (b *Bindings) Unmap(key string) error {
+	keys, err := parseKeys(key)
+	if err != nil {
+		return err
+	}
+	if !unbindKeys(b.keymap, keys) {
+		return fmt.Errorf("'%s' is not bound", key)
+	}
+	delete(b.bindings, seqEvent(keys))
+	return nil
+}
+
+// Keys returns the keys bound, as written.
+func (b *Bindings) Keys() []string {
+	keys := make([]string, 0, len(b.bindings))
+	for _, binding := range b.bindings {
+		keys = append(keys, binding.key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Text returns the commands key is bound to, as written.
+func (b *Bindings) Text(key string) string {
+	keys, err := parseKeys(key)
+	if err != nil {
+		return ""
+	}
+	return b.bindings[seqEvent(keys)].text
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBindingsMap(t *testing.T) {
+	var ran []string
+	cmds := map[string]CMD{
+		"next": func() { ran = append(ran, "next") },
+		"dir":  func(args []string) { ran = append(ran, args...) },
+	}
+	b := NewBindings(nil, make(map[Event][]Action), cmds)
+
+	tests := []struct {
+		key    string
+		script string
+		ok     bool
+	}{
+		{"j", "next", true},
+		{"g g", "next; next", true},
+		{"s", "dir sort_by_size", true},
+		{"enterr", "next", false},
+		{"x", "bogus", false},
+		{"x", "dir bogus", false},
+		{"x", "next 'a", false},
+	}
+	for _, test := range tests {
+		if err := b.Map(test.key, test.script); (err == nil) != test.ok {
+			t.Errorf("Map(%q, %q) = %v", test.key, test.script, err)
+		}
+	}
+	if got, want := b.Keys(), []string{"g g", "j", "s"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %q, want %q", got, want)
+	}
+	if got := b.Text("g g"); got != "next; next" {
+		t.Errorf("Text(g g) = %q", got)
+	}
+
+	HandleAction(Key('s'), &b.keymap)
+	if want := []string{"sort_by_size"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("s ran %q, want %q", ran, want)
+	}
+
+	if err := b.Unmap("g g"); err != nil {
+		t.Error(err)
+	}
+	if err := b.Unmap("g g"); err == nil {
+		t.Error("unmapped g g twice")
+	}
+	if _, ok := b.keymap[prefixEvent([]Event{Key('g')})]; ok {
+		t.Error("g is still a prefix")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Bookmarks maps a key to a directory. They are saved to a file, one
+// "key:path" per line, whenever they change.
+type Bookmarks struct {
+	path  string
+	marks map[rune]string
+}
+
+func LoadBookmarks(path string) (*Bookmarks, error) {
+	b := &Bookmarks{
+		path:  path,
+		marks: make(map[rune]string),
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return b, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		r, n := utf8.DecodeRuneInString(line)
+		if n == 0 || !strings.HasPrefix(line[n:], ":") {
+			continue
+		}
+		b.marks[r] = line[n+1:]
+	}
+	return b, scanner.Err()
+}
+
+func (b *Bookmarks) save() error {
+	var sb strings.Builder
+	for _, r := range b.Keys() {
+		fmt.Fprintf(&sb, "%c:%s\n", r, b.marks[r])
+	}
+
+	return writeFileAtomic(b.path, []byte(sb.String()))
+}
+
+func (b *Bookmarks) Get(r rune) (string, bool) {
+	path, ok := b.marks[r]
+	return path, ok
+}
+
+func (b *Bookmarks) Set(r rune, path string) error {
+	b.marks[r] = path
+	return b.save()
+}
+
+func (b *Bookmarks) Delete(r rune) error {
+	delete(b.marks, r)
+	return b.save()
+}
+
+func (b *Bookmarks) Keys() []rune {
+	keys := make([]rune, 0, len(b.marks))
+	for r := range b.marks {
+		keys = append(keys, r)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// bookmarkKey returns the key given as argument, or reads it from the next
+// key typed.
+func bookmarkKey(keys *KeyReader, args []string, fn func(r rune)) {
+	if arg := ParseArgOrDefault(args, 0, ""); arg != "" {
+		r, _ := utf8.DecodeRuneInString(arg.(string))
+		fn(r)
+		return
+	}
+	keys.Read(fn)
+}
+
+func (b *Bookmarks) SetCMD(c *Controller, keys *KeyReader) CMD {
+	return func(args []string) {
+		bookmarkKey(keys, args, func(r rune) {
+			if err := b.Set(r, c.cwd); err != nil {
+				c.Warn("%+v", err)
+			}
+		})
+	}
+}
+
+func (b *Bookmarks) JumpCMD(c *Controller, keys *KeyReader) CMD {
+	return func(args []string) {
+		bookmarkKey(keys, args, func(r rune) {
+			path, ok := b.Get(r)
+			if !ok {
+				c.Warn("Bookmark '%c' not set", r)
+				return
+			}
+			c.Jump(path)
+		})
+	}
+}
+
+func (b *Bookmarks) ListCMD(c *Controller, menu *Menu) CMD {
+	return func() {
+		keys := b.Keys()
+		items := make([]MenuItem, 0, len(keys))
+		for _, r := range keys {
+			items = append(items, MenuItem{
+				Text:  fmt.Sprintf("%c  %s", r, b.marks[r]),
+				Value: string(r),
+			})
+		}
+
+		menu.Open("Bookmarks", items, func(item MenuItem) {
+			path, _ := b.Get([]rune(item.Value)[0])
+			c.Jump(path)
+		}, func(item MenuItem) error {
+			return b.Delete([]rune(item.Value)[0])
+		})
+	}
+}
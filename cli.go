@@ -1,81 +1,319 @@
 package main
 
 import (
-	"fmt"
+	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	// Rows of the completion popup
+	completionMaxRows = 10
 )
 
+// completion is the state of completing a word of the command line, kept
+// while Tab cycles through the candidates.
+type completion struct {
+	text       string // The command line it applies to
+	head       string // The command line before the word
+	typed      string // The word as typed, kept as it is
+	word       string // The word, unquoted
+	quote      rune   // The quote the word is left in
+	tail       string // The command line after the cursor
+	candidates []string
+	index      int // -1 before cycling
+}
+
+// historyNav is the state of walking the history of a mode, kept while up
+// and down are pressed.
+type historyNav struct {
+	text   string // The command line it applies to
+	prefix string // What was typed before walking, entries have to match it
+	pos    int    // The entry shown, Len() for what was typed
+}
+
 type CLI struct {
-	c         *Controller
-	oldKeymap map[Event][]Action
-	keymap    map[Event][]Action
-	cmds      map[string]CMD
+	c     *Controller
+	modes *Modes
+	cmds  map[string]CMD
+	done  func(string)
+
+	clearFilter bool
+
+	completers map[string]Completer
+	comp       *completion
 
-	prevCMD string
-	cmd     string
-	cursor  int
+	// By mode, ':' or '/'
+	histories map[byte]*LineHistory
+	nav       *historyNav
+
+	prevCMD    string
+	prevCursor int
+	cmd        string
+	cursor     int
+	yank       string
 }
 
-func NewCLI(c *Controller) *CLI {
-	cli := &CLI{
-		c:      c,
-		keymap: make(map[Event][]Action),
+func NewCLI(c *Controller, modes *Modes) *CLI {
+	return &CLI{
+		c:     c,
+		modes: modes,
 	}
-	add := func(ev Event, cmd CMD) {
-		cli.keymap[ev] = []Action{ToAction(cmd, nil)}
+}
+
+// CMDs returns the commands editing the command line, to be added to the
+// command table. They do nothing unless a line is being typed.
+func (c *CLI) CMDs() map[string]CMD {
+	line := func(cmd CMD) CMD {
+		return func(ev Event, keymap *map[Event][]Action, args []string) {
+			if c.cmd != "" {
+				ToAction(cmd, args)(ev, keymap)
+			}
+		}
 	}
 
-	for i := 0; i < 26; i++ {
-		add(Key(rune(i+'a')), cli.Add)
-		add(Key(rune(i+'A')), cli.Add)
+	return map[string]CMD{
+		"backward_delete_char": line(c.Delete),
+		"delete_char":          line(c.DeleteForward),
+		"clear_line":           line(c.Clear),
+		"backward_kill_word":   line(c.KillWordBack),
+		"kill_line":            line(c.KillEnd),
+		"yank":                 line(c.Yank),
+		"insert_paste":         line(c.Paste),
+		"cancel":               line(c.Cancel),
+		"accept_line":          line(c.Enter),
+		"backward_char":        line(c.CursorBack),
+		"forward_char":         line(c.CursorForward),
+		"beginning_of_line":    line(c.CursorBegin),
+		"end_of_line":          line(c.CursorEnd),
+		"backward_word":        line(c.WordBack),
+		"forward_word":         line(c.WordForward),
+		"complete":             line(c.Complete),
+		"complete_back":        line(c.CompleteBack),
+		"history_prev":         line(c.HistoryPrev),
+		"history_next":         line(c.HistoryNext),
 	}
-	add(Key(rune(' ')), cli.Add)
-	add(BSpace.AsEvent(), cli.Delete)
-	add(CtrlU.AsEvent(), cli.Clear)
+}
 
-	add(ESC.AsEvent(), cli.Cancel)
-	add(CtrlC.AsEvent(), cli.Cancel)
-	// enter, return key
-	add(CtrlM.AsEvent(), cli.Enter)
+func cliKeybindings() string {
+	binds := []string{
+		"bs:backward_delete_char",
+		"del:delete_char",
+		"ctrl-u:clear_line",
+		"ctrl-w:backward_kill_word",
+		"alt-bs:backward_kill_word",
+		"ctrl-k:kill_line",
+		"ctrl-y:yank",
+		"paste:insert_paste",
+		"esc:cancel",
+		"ctrl-c:cancel",
+		"enter:accept_line",
+		"ctrl-b:backward_char",
+		"ctrl-f:forward_char",
+		"ctrl-a:beginning_of_line",
+		"ctrl-e:end_of_line",
+		"left:backward_char",
+		"right:forward_char",
+		"home:beginning_of_line",
+		"end:end_of_line",
+		"alt-b:backward_word",
+		"alt-f:forward_word",
+		"ctrl-left:backward_word",
+		"ctrl-right:forward_word",
+		"tab:complete",
+		"shift-tab:complete_back",
+		"up:history_prev",
+		"down:history_next",
+		"ctrl-p:history_prev",
+		"ctrl-n:history_next",
+	}
+	return strings.Join(binds, ",")
+}
 
-	add(CtrlB.AsEvent(), cli.CursorBack)
-	add(CtrlF.AsEvent(), cli.CursorForward)
-	add(CtrlA.AsEvent(), cli.CursorBegin)
-	add(CtrlE.AsEvent(), cli.CursorEnd)
+// filterKeybindings are bound in filter mode over cliKeybindings, to move
+// through the entries shown and mark them while typing.
+func filterKeybindings() string {
+	binds := []string{
+		"ctrl-j:next",
+		"ctrl-k:prev",
+		"down:next",
+		"up:prev",
+		"tab:toggle_mark; next",
+		"shift-tab:toggle_mark; prev",
+	}
+	return strings.Join(binds, ",")
+}
 
-	return cli
+// AddModes adds the modes of the command line to modes: command for ':',
+// filter for '/' and prompt for lines read by StartPrompt. Any rune not
+// bound in them is typed in. The commands of the command line have to be
+// in cmds.
+func (c *CLI) AddModes(cmds map[string]CMD) error {
+	for name, keybindings := range map[string]string{
+		"command": cliKeybindings(),
+		"filter":  cliKeybindings() + "," + filterKeybindings(),
+		"prompt":  cliKeybindings(),
+	} {
+		keymap := make(map[Event][]Action)
+		keymap[Resize.AsEvent()] = []Action{ToAction(cmds["resize"], nil)}
+		keymap[Rune.AsEvent()] = []Action{ToAction(c.Add, nil)}
+		bindings := NewBindings(c.c, keymap, cmds)
+		if err := bindings.Parse(keybindings); err != nil {
+			return err
+		}
+		c.modes.Add(name, bindings)
+	}
+	return nil
 }
 
 func (c *CLI) SetCMDs(cmds map[string]CMD) {
 	c.cmds = cmds
 }
 
-func (c *CLI) StartCMD(ev Event, keymap *map[Event][]Action, _ []string) {
-	c.oldKeymap = *keymap
-	*keymap = c.keymap
+// SetClearFilter sets whether the filter is cleared when entered, instead of
+// kept until it is changed.
+func (c *CLI) SetClearFilter(clear bool) {
+	c.clearFilter = clear
+}
+
+// SetCompleters sets how the arguments of commands are completed, by
+// command name.
+func (c *CLI) SetCompleters(completers map[string]Completer) {
+	c.completers = completers
+}
 
-	c.Add(Key(':'), nil, nil)
+// SetHistories sets where the lines entered are remembered, by mode.
+func (c *CLI) SetHistories(histories map[byte]*LineHistory) {
+	c.histories = histories
 }
 
-func (c *CLI) StartFilter(ev Event, keymap *map[Event][]Action, _ []string) {
-	c.oldKeymap = *keymap
-	*keymap = c.keymap
+func (c *CLI) StartCMD() {
+	c.modes.Enter("command")
+	c.Add(Key(':'), nil, nil)
+}
 
+func (c *CLI) StartFilter() {
+	c.modes.Enter("filter")
 	c.Add(Key('/'), nil, nil)
 }
 
+// StartPrompt reads a line prefixed by prefix and passes it to done on enter,
+// instead of running it as a command.
+func (c *CLI) StartPrompt(prefix rune, done func(string)) {
+	c.modes.Enter("prompt")
+	c.done = done
+	c.Add(Key(prefix), nil, nil)
+}
+
 func (c *CLI) Add(ev Event, _ *map[Event][]Action, _ []string) {
-	c.cmd = c.cmd[:c.cursor] + string(ev.Char) + c.cmd[c.cursor:]
-	c.cursor++
+	if !unicode.IsPrint(ev.Char) {
+		return
+	}
+	c.insert(string(ev.Char))
+}
+
+// insert inserts s at the cursor. The cursor is a byte offset into cmd,
+// always at the start of a rune.
+func (c *CLI) insert(s string) {
+	c.cmd = c.cmd[:c.cursor] + s + c.cmd[c.cursor:]
+	c.cursor += len(s)
 	c.draw()
 }
 
-func (c *CLI) Delete(ev Event, keymap *map[Event][]Action, args []string) {
+// prevRune returns the offset of the rune before i, not going into the
+// prefix.
+func (c *CLI) prevRune(i int) int {
+	if i <= 1 {
+		return 1
+	}
+	_, n := utf8.DecodeLastRuneInString(c.cmd[:i])
+	return i - n
+}
+
+func (c *CLI) nextRune(i int) int {
+	if i >= len(c.cmd) {
+		return len(c.cmd)
+	}
+	_, n := utf8.DecodeRuneInString(c.cmd[i:])
+	return i + n
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordBack returns the start of the word before i. Words are made of
+// letters and digits, or of anything but spaces if space is set.
+func (c *CLI) wordBack(i int, space bool) int {
+	in := func(r rune) bool {
+		if space {
+			return !unicode.IsSpace(r)
+		}
+		return isWordRune(r)
+	}
+	for i > 1 {
+		r, _ := utf8.DecodeLastRuneInString(c.cmd[:i])
+		if in(r) {
+			break
+		}
+		i = c.prevRune(i)
+	}
+	for i > 1 {
+		r, _ := utf8.DecodeLastRuneInString(c.cmd[:i])
+		if !in(r) {
+			break
+		}
+		i = c.prevRune(i)
+	}
+	return i
+}
+
+func (c *CLI) wordForward(i int) int {
+	for i < len(c.cmd) {
+		r, _ := utf8.DecodeRuneInString(c.cmd[i:])
+		if isWordRune(r) {
+			break
+		}
+		i = c.nextRune(i)
+	}
+	for i < len(c.cmd) {
+		r, _ := utf8.DecodeRuneInString(c.cmd[i:])
+		if !isWordRune(r) {
+			break
+		}
+		i = c.nextRune(i)
+	}
+	return i
+}
+
+// kill cuts cmd from i to j, to be pasted again by Yank.
+func (c *CLI) kill(i, j int) {
+	if i == j {
+		return
+	}
+	c.yank = c.cmd[i:j]
+	c.cmd = c.cmd[:i] + c.cmd[j:]
+	c.cursor = i
+	c.draw()
+}
+
+func (c *CLI) Delete() {
 	if c.cursor > 1 {
-		c.cmd = c.cmd[:c.cursor-1] + c.cmd[c.cursor:]
-		c.cursor--
+		i := c.prevRune(c.cursor)
+		c.cmd = c.cmd[:i] + c.cmd[c.cursor:]
+		c.cursor = i
 	} else {
-		c.Cancel(ev, keymap, args)
+		c.Cancel()
+	}
+	c.draw()
+}
+
+func (c *CLI) DeleteForward() {
+	if c.cursor < len(c.cmd) {
+		c.cmd = c.cmd[:c.cursor] + c.cmd[c.nextRune(c.cursor):]
 	}
 	c.draw()
 }
@@ -86,17 +324,42 @@ func (c *CLI) Clear() {
 	c.draw()
 }
 
+// KillWordBack cuts the word before the cursor, up to a space.
+func (c *CLI) KillWordBack() {
+	c.kill(c.wordBack(c.cursor, true), c.cursor)
+}
+
+// KillEnd cuts the line from the cursor to the end.
+func (c *CLI) KillEnd() {
+	c.kill(c.cursor, len(c.cmd))
+}
+
+// Paste inserts pasted text, on one line.
+func (c *CLI) Paste(ev Event, _ *map[Event][]Action, _ []string) {
+	text := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsSpace(r):
+			return ' '
+		case !unicode.IsPrint(r):
+			return -1
+		}
+		return r
+	}, strings.TrimRight(ev.Text, "\r\n"))
+	c.insert(text)
+}
+
+// Yank pastes what was cut last.
+func (c *CLI) Yank() {
+	c.insert(c.yank)
+}
+
 func (c *CLI) CursorBack() {
-	if c.cursor > 1 {
-		c.cursor--
-	}
+	c.cursor = c.prevRune(c.cursor)
 	c.draw()
 }
 
 func (c *CLI) CursorForward() {
-	if c.cursor < len(c.cmd) {
-		c.cursor++
-	}
+	c.cursor = c.nextRune(c.cursor)
 	c.draw()
 }
 
@@ -110,11 +373,219 @@ func (c *CLI) CursorEnd() {
 	c.draw()
 }
 
-func (c *CLI) Cancel(ev Event, keymap *map[Event][]Action, args []string) {
+func (c *CLI) WordBack() {
+	c.cursor = c.wordBack(c.cursor, false)
+	c.draw()
+}
+
+func (c *CLI) WordForward() {
+	c.cursor = c.wordForward(c.cursor)
+	c.draw()
+}
+
+// Complete completes the command name or argument before the cursor, as
+// far as it is unambiguous. Pressed again, it cycles through the
+// candidates, shown in a popup.
+func (c *CLI) Complete() {
+	c.complete(1)
+}
+
+func (c *CLI) CompleteBack() {
+	c.complete(-1)
+}
+
+func (c *CLI) complete(step int) {
+	if c.cmd == "" || c.cmd[0] != ':' || c.done != nil {
+		return
+	}
+
+	if comp := c.comp; comp != nil && comp.text == c.cmd {
+		n := len(comp.candidates)
+		if comp.index == -1 && step < 0 {
+			comp.index = n - 1
+		} else {
+			comp.index = ((comp.index+step)%n + n) % n
+		}
+		c.setCompletion(comp.candidates[comp.index], false)
+		return
+	}
+
+	before, after := c.cmd[:c.cursor], c.cmd[c.cursor:]
+	words, start, quote := splitTyped(before[1:])
+	if quote == '\\' {
+		return
+	}
+	word := words[len(words)-1]
+
+	var all []string
+	if len(words) == 1 {
+		for name := range c.cmds {
+			all = append(all, name)
+		}
+	} else if completer, ok := c.completers[words[0]]; ok {
+		all = completer(words[1:])
+	}
+
+	var candidates []string
+	for _, s := range all {
+		if strings.HasPrefix(s, word) {
+			candidates = append(candidates, s)
+		}
+	}
+	sort.Strings(candidates)
+	if len(candidates) == 0 {
+		return
+	}
+
+	c.comp = &completion{
+		head:       before[:1+start],
+		typed:      before[1+start:],
+		word:       word,
+		quote:      quote,
+		tail:       after,
+		candidates: candidates,
+		index:      -1,
+	}
+	if len(candidates) == 1 {
+		c.setCompletion(candidates[0], !strings.HasSuffix(candidates[0], "/"))
+		c.endCompletion()
+		return
+	}
+	c.setCompletion(commonPrefix(candidates), false)
+	c.c.SetOverlay(c)
+}
+
+// setCompletion puts candidate in place of the word completed. What was
+// typed of it is kept, the rest is quoted as the word is. A final
+// candidate also ends the word.
+func (c *CLI) setCompletion(candidate string, final bool) {
+	comp := c.comp
+	word := comp.typed + quoteTyped(candidate[len(comp.word):], comp.quote)
+	if final {
+		if comp.quote != 0 {
+			word += string(comp.quote)
+		}
+		word += " "
+	}
+	c.cmd = comp.head + word + comp.tail
+	c.cursor = len(comp.head) + len(word)
+	comp.text = c.cmd
+	c.draw()
+}
+
+func (c *CLI) endCompletion() {
+	if c.comp == nil {
+		return
+	}
+	c.comp = nil
+	c.c.SetOverlay(nil)
+	c.c.Draw()
+}
+
+// Draw draws the candidates of the completion above the command line,
+// starting at the word completed.
+func (c *CLI) Draw() {
+	comp := c.comp
+	if comp == nil {
+		return
+	}
+
+	width, height := c.c.screen.Size()
+	w := 0
+	for _, s := range comp.candidates {
+		if n := runewidth.StringWidth(s) + 2; n > w {
+			w = n
+		}
+	}
+	w = min(w, width)
+	rows := min(len(comp.candidates), min(completionMaxRows, height-2))
+	if rows <= 0 {
+		return
+	}
+	x := min(runewidth.StringWidth(comp.head), width-w)
+
+	win := &Win{
+		X1:     x,
+		X2:     x + w - 1,
+		Y1:     height - 1 - rows,
+		Y2:     height - 2,
+		Screen: c.c.screen,
+	}
+	st := c.c.cli.Style.Reverse(true)
+	selectedSt := c.c.cli.Style
+	win.Reset(st)
+
+	begin := 0
+	if comp.index >= rows {
+		begin = comp.index - rows + 1
+	}
+	for i := 0; i < rows; i++ {
+		j := begin + i
+		style := &st
+		if j == comp.index {
+			style = &selectedSt
+		}
+		contents := make(ListItem, 0, w)
+		contents.WriteString(" "+comp.candidates[j], style)
+		for len(contents) < w {
+			contents.WriteContent(' ', style)
+		}
+		win.Render(0, i, contents, st, false)
+	}
+}
+
+// HistoryPrev replaces the line by the previous entry of the history of the
+// mode, among those starting with what was typed.
+func (c *CLI) HistoryPrev() {
+	c.walkHistory(-1)
+}
+
+func (c *CLI) HistoryNext() {
+	c.walkHistory(1)
+}
+
+func (c *CLI) walkHistory(step int) {
+	if c.cmd == "" || c.done != nil {
+		return
+	}
+	h := c.histories[c.cmd[0]]
+	if h == nil {
+		return
+	}
+
+	nav := c.nav
+	if nav == nil || nav.text != c.cmd {
+		nav = &historyNav{prefix: c.cmd[1:], pos: h.Len()}
+	}
+
+	var line string
+	i := h.Search(nav.prefix, nav.pos, step)
+	switch {
+	case i != -1:
+		line = h.Get(i)
+	case step > 0 && nav.pos != h.Len():
+		// Back to what was typed
+		i = h.Len()
+		line = nav.prefix
+	default:
+		return
+	}
+
+	nav.pos = i
+	c.cmd = c.cmd[:1] + line
+	c.cursor = len(c.cmd)
+	nav.text = c.cmd
+	c.nav = nav
+	c.draw()
+}
+
+func (c *CLI) Cancel() {
 	c.cmd = ""
 	c.cursor = 0
-	*keymap = c.oldKeymap
+	c.modes.Leave()
 	c.draw()
+	c.done = nil
+	c.nav = nil
 }
 
 func (c *CLI) Enter(ev Event, keymap *map[Event][]Action, args []string) {
@@ -124,33 +595,61 @@ func (c *CLI) Enter(ev Event, keymap *map[Event][]Action, args []string) {
 	c.prevCMD = ""
 	c.cmd = ""
 	c.cursor = 0
-	*keymap = c.oldKeymap
+	c.modes.Leave()
+	c.endCompletion()
+	c.nav = nil
 	c.c.CMD(c.cmd, c.cursor)
 
-	if mode == ':' {
-		tokens := strings.Split(spec, " ")
-		cmd, ok := c.cmds[tokens[0]]
-		if ok {
-			action := ToAction(cmd, tokens[1:])
-			action(ev, keymap)
-		} else {
-			c.c.Warn("Command '%s' not found", tokens[0])
+	if c.done != nil {
+		done := c.done
+		c.done = nil
+		done(spec)
+		return
+	}
+
+	if h := c.histories[mode]; h != nil {
+		if err := h.Add(spec); err != nil {
+			c.c.Warn("%+v", err)
+		}
+	}
+
+	if mode == '/' && c.clearFilter {
+		c.c.Filter("")
+	}
+
+	if mode == ':' && strings.TrimSpace(spec) != "" {
+		action, err := CompileScript(spec, c.cmds, c.c)
+		if err != nil {
+			c.c.Warn("%v", err)
+			return
 		}
+		action(ev, keymap)
 	}
 }
 
 func (c *CLI) draw() {
 	if c.prevCMD == c.cmd {
+		if c.prevCursor != c.cursor {
+			c.prevCursor = c.cursor
+			c.c.CMD(c.cmd, c.cursor)
+		}
 		return
 	}
+	c.prevCursor = c.cursor
 	prevCMD := c.prevCMD
 	c.prevCMD = c.cmd
-	if c.cmd == "" {
+	// Anything but Tab ends the completion
+	if c.comp != nil && c.comp.text != c.cmd {
+		c.endCompletion()
+	}
+	if c.done != nil {
+		// Prompts do not filter
+	} else if c.cmd == "" {
 		if prevCMD[0] == '/' {
-			c.c.DirDo([]string{"filter"})
+			c.c.Filter("")
 		}
 	} else if c.cmd[0] == '/' {
-		c.c.DirDo([]string{fmt.Sprintf("filter %s", c.cmd[1:])})
+		c.c.Filter(c.cmd[1:])
 	}
 	c.c.CMD(c.cmd, c.cursor)
 }
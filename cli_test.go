@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestCLIComplete(t *testing.T) {
+	fsys := newTestFS(t)
+	for _, err := range []error{
+		fsys.MkdirAll("/home/u/My Dir", 0755),
+		fsys.WriteFile("/home/u/My Dir/it's", nil, 0644),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := newTestController(t, fsys, "/home/u")
+
+	cli := NewCLI(c, NewModes(c))
+	cli.SetCMDs(map[string]CMD{
+		"cd":   func() {},
+		"view": func() {},
+	})
+	path := func(dirOnly bool) Completer {
+		return func(args []string) []string {
+			return completePath(fsys, c.cwd, args[len(args)-1], dirOnly)
+		}
+	}
+	cli.SetCompleters(map[string]Completer{
+		"cd":   path(true),
+		"view": path(false),
+	})
+
+	tests := []struct {
+		line string
+		want string
+	}{
+		{":v", ":view "},
+		{":next; v", ":next; view "},
+		{":cd M", `:cd My\ Dir/`},
+		{`:cd My\ D`, `:cd My\ Dir/`},
+		{":cd 'My D", ":cd 'My Dir/"},
+		{`:cd "My D`, `:cd "My Dir/`},
+		{`:cd ~/x\`, `:cd ~/x\`},
+		{":view 'My Dir/i", `:view 'My Dir/it'\''s' `},
+		{`:view "My Dir/i`, `:view "My Dir/it's" `},
+		{":view My\\ Dir/i", `:view My\ Dir/it\'s `},
+		// What was typed is kept
+		{":view M'y D'ir/i", `:view M'y D'ir/it\'s `},
+	}
+	for _, test := range tests {
+		cli.cmd, cli.cursor = test.line, len(test.line)
+		cli.Complete()
+		if cli.cmd != test.want {
+			t.Errorf("completing %s gave %s, want %s", test.line, cli.cmd, test.want)
+		}
+		cli.endCompletion()
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
 )
 
 type CLIView struct {
@@ -12,6 +13,7 @@ type CLIView struct {
 	Info     *FileInfo
 	Style    tcell.Style
 	hideInfo bool
+	pending  string
 }
 
 func (v *CLIView) ShowInfo() {
@@ -21,20 +23,46 @@ func (v *CLIView) ShowInfo() {
 
 	v.Win.Reset(v.Style)
 
-	if v.Info == nil {
-		return
+	if v.Info != nil {
+		v.Win.Render(0, 0, v.Info.Info(), v.Style, false)
 	}
 
-	v.Win.Render(0, 0, v.Info.Info(), v.Style, false)
+	// Count and keys typed so far of a sequence, on the right
+	if v.pending != "" {
+		contents := make(ListItem, 0, len(v.pending)+1)
+		contents.WriteString(v.pending+" ", nil)
+		x := v.Win.W() - len(contents)
+		if x < 0 {
+			x = 0
+		}
+		v.Win.Render(x, 0, contents, v.Style, false)
+	}
+}
+
+// Pending shows the count and keys typed so far of a sequence.
+func (v *CLIView) Pending(keys string) {
+	v.pending = keys
+	v.ShowInfo()
 }
 
 func (v *CLIView) Warn(format string, a ...any) {
+	v.show(v.Style.Background(tcell.ColorRed), format, a...)
+}
+
+// Notify shows a message for a while, like Warn but not as an error.
+func (v *CLIView) Notify(format string, a ...any) {
+	v.show(v.Style, format, a...)
+}
+
+func (v *CLIView) show(style tcell.Style, format string, a ...any) {
 	v.hideInfo = true
 	v.Win.Reset(v.Style)
-	v.Win.RenderANSI(0, 0, fmt.Sprintf(format, a...), v.Style.Background(tcell.ColorRed))
+	v.Win.RenderANSI(0, 0, fmt.Sprintf(format, a...), style)
 	time.AfterFunc(3 * time.Second, func() { v.hideInfo = false })
 }
 
+// CMD shows the line s being edited, with the cursor at the byte offset
+// cursor. The line scrolls sideways to keep the cursor in view.
 func (v *CLIView) CMD(s string, cursor int) {
 	v.hideInfo = s != ""
 	v.Win.Reset(v.Style)
@@ -47,18 +75,26 @@ func (v *CLIView) CMD(s string, cursor int) {
 	if cursor > len(s) {
 		cursor = len(s)
 	}
-	contents := make(ListItem, 0, len(s)+1)
+
+	width := v.Win.W()
+	x := 0
+	if before := runewidth.StringWidth(s[:cursor]); before >= width {
+		x = width - 1 - before
+	}
+
 	st := v.Style.Reverse(true)
 	for i, r := range s {
+		w := runewidth.RuneWidth(r)
+		style := v.Style
 		if i == cursor {
-			contents.WriteContent(r, &st)
-		} else {
-			contents.WriteContent(r, nil)
+			style = st
+		}
+		if x >= 0 && x+w <= width && w > 0 {
+			v.Win.Screen.SetContent(v.Win.X1+x, v.Win.Y1, r, nil, style)
 		}
+		x += w
 	}
-	if cursor == len(s) {
-		contents.WriteContent(' ', &st)
+	if cursor == len(s) && x < width {
+		v.Win.Screen.SetContent(v.Win.X1+x, v.Win.Y1, ' ', nil, st)
 	}
-
-	v.Win.Render(0, 0, contents, v.Style, true)
 }
@@ -5,6 +5,9 @@ import (
 	"strconv"
 )
 
+// CMD is one of func(), func(args []string), func(count int, args []string)
+// or func(ev Event, keymap *map[Event][]Action, args []string). count is
+// the number typed before the key, 0 if none, also in ev.Count.
 type CMD interface{}
 
 func ToAction(cmd CMD, args []string) Action {
@@ -17,6 +20,10 @@ func ToAction(cmd CMD, args []string) Action {
 		return func(ev Event, keymap *map[Event][]Action) {
 			f(args)
 		}
+	case func(count int, args []string):
+		return func(ev Event, keymap *map[Event][]Action) {
+			f(ev.Count, args)
+		}
 	case func(ev Event, keymap *map[Event][]Action, args []string):
 		return func(ev Event, keymap *map[Event][]Action) {
 			f(ev, keymap, args)
@@ -42,6 +49,12 @@ func ParseArgOrDefault(args []string, pos int, default_ any) any {
 			return default_
 		}
 		return i
+	case bool:
+		b, err := strconv.ParseBool(arg)
+		if err != nil {
+			return default_
+		}
+		return b
 	default:
 		log.Fatalf("not support type %T", default_)
 	}
@@ -49,3 +62,11 @@ func ParseArgOrDefault(args []string, pos int, default_ any) any {
 	// unreachable
 	return nil
 }
+
+// countOr returns count, or n if no count was typed.
+func countOr(count, n int) int {
+	if count == 0 {
+		return n
+	}
+	return count
+}
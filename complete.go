@@ -0,0 +1,80 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Completer returns the candidates for the last of args, the one being
+// typed. Those not starting with it are dropped by the caller.
+type Completer func(args []string) []string
+
+// completeWords completes every argument to one of words.
+func completeWords(words ...string) Completer {
+	return func([]string) []string {
+		return words
+	}
+}
+
+// completePath returns the completions of arg to files, and directories
+// with a trailing slash, only those if dirOnly is set. The part of arg
+// before the last slash is kept as typed, it is only expanded to read the
+// directory.
+func completePath(fsys FS, cwd, arg string, dirOnly bool) []string {
+	typedDir, base := "", arg
+	if i := strings.LastIndex(arg, "/"); i != -1 {
+		typedDir, base = arg[:i+1], arg[i+1:]
+	}
+
+	dir := cwd
+	if typedDir != "" {
+		dir = expandPath(cwd, typedDir)
+	}
+
+	infos, err := fsys.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var candidates []string
+	for _, info := range infos {
+		name := info.Name()
+		if !strings.HasPrefix(name, base) {
+			continue
+		}
+		// Hidden ones only if asked for
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		isDir := info.IsDir()
+		if !isDir {
+			fi, err := fsys.Stat(filepath.Join(dir, name))
+			isDir = err == nil && fi.IsDir()
+		}
+		switch {
+		case isDir:
+			candidates = append(candidates, typedDir+name+"/")
+		case !dirOnly:
+			candidates = append(candidates, typedDir+name)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// commonPrefix returns the longest prefix of whole runes all of a share.
+func commonPrefix(a []string) string {
+	if len(a) == 0 {
+		return ""
+	}
+	prefix := a[0]
+	for _, s := range a[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			_, n := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-n]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		a    []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"abc"}, "abc"},
+		{[]string{"abc", "abd"}, "ab"},
+		{[]string{"abc", "xyz"}, ""},
+		{[]string{"abc", "ab", "abcd"}, "ab"},
+		// Runes sharing their first bytes are not cut in half
+		{[]string{"aé", "aè"}, "a"},
+		{[]string{"日本", "日曜"}, "日"},
+		{[]string{"😀a", "😁a"}, ""},
+	}
+	for _, test := range tests {
+		if got := commonPrefix(test.a); got != test.want {
+			t.Errorf("commonPrefix(%q) = %q, want %q", test.a, got, test.want)
+		}
+	}
+}
+
+func TestCompletePath(t *testing.T) {
+	fsys := newTestFS(t)
+	if err := fsys.WriteFile("/home/u/.hidden", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		arg     string
+		dirOnly bool
+		want    []string
+	}{
+		{"", false, []string{"docs/", "link/", "notes.md", "src/"}},
+		{"", true, []string{"docs/", "link/", "src/"}},
+		{"d", false, []string{"docs/"}},
+		{".", false, []string{".hidden"}},
+		{"docs/", false, []string{"docs/B.txt", "docs/a.txt"}},
+		{"link/a", false, []string{"link/a.txt"}},
+		{"/home/u/s", false, []string{"/home/u/src/"}},
+		{"missing/", false, nil},
+	}
+	for _, test := range tests {
+		got := completePath(fsys, "/home/u", test.arg, test.dirOnly)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("completePath(%q, %v) = %q, want %q", test.arg, test.dirOnly, got, test.want)
+		}
+	}
+}
+
+func TestSplitTyped(t *testing.T) {
+	tests := []struct {
+		s     string
+		words []string
+		start int
+		quote rune
+	}{
+		{"", []string{""}, 0, 0},
+		{"cd", []string{"cd"}, 0, 0},
+		{"cd ", []string{"cd", ""}, 3, 0},
+		{"cd a b", []string{"cd", "a", "b"}, 5, 0},
+		{"next; cd a", []string{"cd", "a"}, 9, 0},
+		{"cd 'My Dir/", []string{"cd", "My Dir/"}, 3, '\''},
+		{`cd "My Dir/`, []string{"cd", "My Dir/"}, 3, '"'},
+		{`cd My\ Dir/`, []string{"cd", "My Dir/"}, 3, 0},
+		{`cd a'b c'd`, []string{"cd", "ab cd"}, 3, 0},
+		{`cd 'a;b`, []string{"cd", "a;b"}, 3, '\''},
+		{`cd "a\$b\c`, []string{"cd", `a$b\c`}, 3, '"'},
+		{`cd 'a\`, []string{"cd", `a\`}, 3, '\''},
+		{"cd $HOME/x", []string{"cd", "$HOME/x"}, 3, 0},
+		{`cd x\`, []string{"cd", "x"}, 3, '\\'},
+	}
+	for _, test := range tests {
+		words, start, quote := splitTyped(test.s)
+		if !reflect.DeepEqual(words, test.words) || start != test.start || quote != test.quote {
+			t.Errorf("splitTyped(%q) = %q, %d, %q, want %q, %d, %q", test.s, words, start, quote, test.words, test.start, test.quote)
+		}
+	}
+}
+
+func TestQuoteTyped(t *testing.T) {
+	tests := []struct {
+		s     string
+		quote rune
+		want  string
+	}{
+		{"abc", 0, "abc"},
+		{"a b;c", 0, `a\ b\;c`},
+		{`it's "$x"`, 0, `it\'s\ \"\$x\"`},
+		{"it's", '\'', `it'\''s`},
+		{`a "$b" \ %`, '"', `a \"\$b\" \\ \%`},
+	}
+	for _, test := range tests {
+		got := quoteTyped(test.s, test.quote)
+		if got != test.want {
+			t.Errorf("quoteTyped(%q, %q) = %q, want %q", test.s, test.quote, got, test.want)
+		}
+		// Typed after the quote, it reads back as s
+		words, _, _ := splitTyped(string(test.quote) + got)
+		if test.quote == 0 {
+			words, _, _ = splitTyped(got)
+		}
+		if words[0] != test.s {
+			t.Errorf("quoteTyped(%q, %q) reads back as %q", test.s, test.quote, words[0])
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadConfig runs the commands in the file at path, one script per line.
+// Empty lines and those starting with '#' are skipped. A missing file is
+// not an error, a bad line is reported and skipped.
+func LoadConfig(path string, cmds map[string]CMD, vars Vars, keymap *map[Event][]Action) []error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return []error{err}
+	}
+	defer f.Close()
+
+	var errs []error
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		action, err := CompileScript(line, cmds, vars)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %v", path, n, err))
+			continue
+		}
+		action(Event{}, keymap)
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
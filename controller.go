@@ -1,10 +1,12 @@
 package main
 
 import (
-	"log"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
 )
@@ -14,29 +16,40 @@ type listViewState struct {
 	offset   int // distance to top
 }
 
+// Drawer is drawn over the panes, e.g. a full screen pager.
+type Drawer interface {
+	Draw()
+}
+
 type Controller struct {
+	fsys            FS
 	dirs            *DirSet
+	previewer       *Previewer
 	path            *PathView
 	cli             *CLIView
 	left            *ListView
 	main            *ListView
+	preview         *PreviewView
+	showPreview     bool
+	overlay         Drawer
 	screen          tcell.Screen
 	cwd             string
 	cwdInited       bool
 	parentCwd       string
 	parentCwdInited bool
-	marks           map[string]struct{}
+	levelsIn        int    // Left to go in when cwd is read, see InLevels
+	reselect        string // Path to select again when cwd is read, see Filter
+	marks           *Selection
 	listViewStates  map[string]listViewState
+	history         History
+	frecency        *Frecency
+	modes           *Modes
 	dirInfoCMD      []string
 }
 
-func NewController(dirs *DirSet, marks map[string]struct{}, screen tcell.Screen) *Controller {
+func NewController(fsys FS, cwd string, dirs *DirSet, previewer *Previewer, marks *Selection, screen tcell.Screen) *Controller {
 	defStyle := tcell.StyleDefault
 
-	cwd, err := os.Getwd()
-	if err != nil {
-		log.Fatalf("%+v", err)
-	}
 	dirs.Add(cwd, nil)
 
 	parentCwd := ""
@@ -67,45 +80,68 @@ func NewController(dirs *DirSet, marks map[string]struct{}, screen tcell.Screen)
 		},
 	}
 
+	preview := &PreviewView{
+		List: ListView{
+			List: List{
+				Style: defStyle,
+				Marks: marks,
+			},
+		},
+		Style: defStyle,
+	}
+
 	c := &Controller{
+		fsys:           fsys,
 		dirs:           dirs,
+		previewer:      previewer,
 		path:           path,
 		cli:            &CLIView{},
 		left:           left,
 		main:           main,
+		preview:        preview,
 		screen:         screen,
 		cwd:            cwd,
 		parentCwd:      parentCwd,
 		marks:          marks,
 		listViewStates: initListViewStates(cwd),
 	}
+	c.history.Visit(cwd)
 	c.resize()
 
 	return c
 }
 
 func initListViewStates(cwd string) map[string]listViewState {
+	cache := make(map[string]listViewState)
+	updateListViewStates(cache, cwd)
+	return cache
+}
+
+// updateListViewStates makes every ancestor of cwd select the next
+// directory on the way to it.
+func updateListViewStates(cache map[string]listViewState, cwd string) {
 	if cwd == "" || cwd == "/" || !strings.HasPrefix(cwd, "/") {
-		return make(map[string]listViewState)
+		return
 	}
 	if strings.HasSuffix(cwd, "/") {
 		cwd = cwd[:len(cwd)-1]
 	}
 
 	tokens := strings.Split(cwd, string(filepath.Separator))
-	cache := make(map[string]listViewState, len(tokens)-1)
 	path := "/"
 	for _, token := range tokens[1:] {
-		cache[path] = listViewState{
-			selected: token,
+		if cache[path].selected != token {
+			cache[path] = listViewState{
+				selected: token,
+			}
 		}
 		path = filepath.Join(path, token)
 	}
-	return cache
 }
 
 func (c *Controller) Show() {
-	if info := c.main.List.GetFileInfo(c.main.SelectAt); info != nil {
+	info := c.main.List.GetFileInfo(c.main.SelectAt)
+	if info != nil {
 		if info.Path != c.path.Path {
 			c.path.Path = info.Path
 			c.path.Draw()
@@ -115,9 +151,97 @@ func (c *Controller) Show() {
 		c.cli.ShowInfo()
 	}
 
+	c.updatePreview(info)
+	c.dirs.Retain(c.cwd, c.parentCwd, c.preview.Path)
+
+	// Only where an action ends up, not every step of it, e.g. out and in
+	// again to move in the parent pane
+	if c.cwd != c.history.Current() {
+		c.history.Visit(c.cwd)
+		c.visitFrecency()
+	}
+
+	if c.overlay != nil {
+		c.overlay.Draw()
+	}
+
 	c.screen.Show()
 }
 
+// SetModes sets the modes the Controller enters, visual mode for one.
+func (c *Controller) SetModes(modes *Modes) {
+	c.modes = modes
+}
+
+// SetFrecency makes visited directories ranked in f.
+func (c *Controller) SetFrecency(f *Frecency) {
+	c.frecency = f
+	c.visitFrecency()
+}
+
+func (c *Controller) visitFrecency() {
+	if c.frecency == nil {
+		return
+	}
+	if err := c.frecency.Visit(c.cwd); err != nil {
+		c.cli.Warn("%+v", err)
+	}
+}
+
+// SetOverlay shows o over the panes until it is set to nil.
+func (c *Controller) SetOverlay(o Drawer) {
+	c.overlay = o
+}
+
+// Var gives the values of %f, %F and %d in commands.
+func (c *Controller) Var(name rune) []string {
+	switch name {
+	case 'f':
+		if info := c.Selected(); info != nil {
+			return []string{info.Path}
+		}
+	case 'F':
+		return c.marks.Sorted()
+	case 'd':
+		return []string{c.cwd}
+	}
+	return nil
+}
+
+func (c *Controller) Selected() *FileInfo {
+	return c.main.List.GetFileInfo(c.main.SelectAt)
+}
+
+func (c *Controller) updatePreview(info *FileInfo) {
+	var path string
+	if info != nil && c.preview.Win != nil {
+		path = info.Path
+	}
+	if path == c.preview.Path {
+		return
+	}
+
+	c.preview.Clear(path)
+	if path != "" {
+		c.previewer.Load(path, c.preview.Win.W(), c.preview.Win.H()+1)
+	} else {
+		c.previewer.Cancel()
+	}
+	if c.preview.Win != nil {
+		c.preview.Draw()
+	}
+}
+
+// acquireDir makes sure path is in the DirSet and that its rows are sent
+// again, even if it was loaded before, e.g. for the preview.
+func (c *Controller) acquireDir(path string, cmds []string) {
+	if dir := c.dirs.Get(path); dir != nil {
+		dir.Do(cmds)
+		return
+	}
+	c.dirs.Add(path, cmds)
+}
+
 func (c *Controller) resize() {
 	width, height := c.screen.Size()
 
@@ -141,25 +265,44 @@ func (c *Controller) resize() {
 	}
 	c.cli.ShowInfo()
 
-	// Left 1/3
+	// Left 1/3, right 2/3, or 1/6, 1/3 and 1/2 with the preview
+	leftX2, mainX2 := width/3, width
+	showPreview := c.showPreview && width >= previewMinWidth
+	if showPreview {
+		leftX2, mainX2 = width/6, width/2
+	}
+
 	c.left.Win = &Win{
 		X1:     0,
-		X2:     width / 3,
+		X2:     leftX2,
 		Y1:     1,
 		Y2:     height - 2,
 		Screen: c.screen,
 	}
 	c.left.Draw()
 
-	// Right 2/3
 	c.main.Win = &Win{
-		X1:     width/3 + 1,
-		X2:     width,
+		X1:     leftX2 + 1,
+		X2:     mainX2,
 		Y1:     1,
 		Y2:     height - 2,
 		Screen: c.screen,
 	}
 	c.main.Draw()
+
+	// The height may have changed, load the preview again
+	c.preview.Clear("")
+	c.preview.Win = nil
+	if showPreview {
+		c.preview.Win = &Win{
+			X1:     mainX2 + 1,
+			X2:     width,
+			Y1:     1,
+			Y2:     height - 2,
+			Screen: c.screen,
+		}
+		c.preview.Draw()
+	}
 }
 
 func (c *Controller) Resize() {
@@ -167,23 +310,41 @@ func (c *Controller) Resize() {
 	c.screen.Sync()
 }
 
-func (c *Controller) Next() {
-	c.main.SelectAt++
+// Draw draws the panes again without laying them out, e.g. over what a
+// popup left.
+func (c *Controller) Draw() {
+	c.path.Draw()
+	c.left.Draw()
 	c.main.Draw()
+	if c.preview.Win != nil {
+		c.preview.Draw()
+	}
+}
+
+func (c *Controller) TogglePreview() {
+	c.showPreview = !c.showPreview
+	c.resize()
 }
 
-func (c *Controller) Prev() {
-	c.main.SelectAt--
+func (c *Controller) Next(count int, _ []string) {
+	c.main.SelectAt += countOr(count, 1)
 	c.main.Draw()
 }
 
-func (c *Controller) Top() {
-	c.main.SelectAt = 0
+func (c *Controller) Prev(count int, _ []string) {
+	c.main.SelectAt -= countOr(count, 1)
+	c.main.Draw()
+}
+
+// Top selects the first entry, or the count-th one if given.
+func (c *Controller) Top(count int, _ []string) {
+	c.main.SelectAt = countOr(count, 1) - 1
 	c.main.Draw()
 }
 
-func (c *Controller) Bottom() {
-	c.main.SelectAt = c.main.List.Size() - 1
+// Bottom selects the last entry, or the count-th one if given.
+func (c *Controller) Bottom(count int, _ []string) {
+	c.main.SelectAt = countOr(count, c.main.List.Size()) - 1
 	c.main.Draw()
 }
 
@@ -228,9 +389,176 @@ func (c *Controller) PageUp() {
 }
 
 func (c *Controller) ClearMarks() {
-	for mark := range c.marks {
-		delete(c.marks, mark)
+	c.marks.Clear()
+}
+
+// NextMark selects the count-th marked entry below the one selected.
+func (c *Controller) NextMark(count int, _ []string) {
+	c.nextMark(countOr(count, 1), 1)
+}
+
+func (c *Controller) PrevMark(count int, _ []string) {
+	c.nextMark(countOr(count, 1), -1)
+}
+
+func (c *Controller) nextMark(n, step int) {
+	found := false
+	for i := c.main.SelectAt + step; i >= 0 && i < c.main.List.Size() && n > 0; i += step {
+		info := c.main.List.GetFileInfo(i)
+		if info == nil || !c.marks.Has(info.Path) {
+			continue
+		}
+		c.main.SelectAt = i
+		found = true
+		n--
+	}
+	if !found {
+		c.Notify("No more marks")
+		return
+	}
+	c.main.Draw()
+}
+
+// markWhere marks, or unmarks, the entries shown in the main pane that
+// match, so the filter is respected, and tells how many changed.
+func (c *Controller) markWhere(unmark bool, match func(info *FileInfo) bool) {
+	n := 0
+	for i := 0; i < c.main.List.Size(); i++ {
+		info := c.main.List.GetFileInfo(i)
+		if info == nil || !match(info) {
+			continue
+		}
+		if c.marks.Has(info.Path) == unmark {
+			if unmark {
+				c.marks.Remove(info.Path)
+			} else {
+				c.marks.Add(info.Path)
+			}
+			n++
+		}
+	}
+	c.main.Draw()
+
+	if unmark {
+		c.Notify("Unmarked %s", entries(n))
+	} else {
+		c.Notify("Marked %s", entries(n))
+	}
+}
+
+func entries(n int) string {
+	if n == 1 {
+		return "1 entry"
+	}
+	return fmt.Sprintf("%d entries", n)
+}
+
+func (c *Controller) MarkAll() {
+	c.markWhere(false, func(*FileInfo) bool { return true })
+}
+
+// InvertMarks marks the entries shown that are not, and unmarks the others.
+func (c *Controller) InvertMarks() {
+	marked, unmarked := 0, 0
+	for i := 0; i < c.main.List.Size(); i++ {
+		info := c.main.List.GetFileInfo(i)
+		if info == nil {
+			continue
+		}
+		if c.marks.Has(info.Path) {
+			c.marks.Remove(info.Path)
+			unmarked++
+		} else {
+			c.marks.Add(info.Path)
+			marked++
+		}
+	}
+	c.main.Draw()
+	c.Notify("Marked %s, unmarked %s", entries(marked), entries(unmarked))
+}
+
+// MarkGlob marks the entries whose name matches any of the patterns, as
+// for filepath.Match.
+func (c *Controller) MarkGlob(patterns []string) {
+	c.markGlob(false, patterns)
+}
+
+func (c *Controller) UnmarkGlob(patterns []string) {
+	c.markGlob(true, patterns)
+}
+
+func (c *Controller) markGlob(unmark bool, patterns []string) {
+	if len(patterns) == 0 {
+		c.Warn("Usage: mark_glob|unmark_glob <pattern>...")
+		return
+	}
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			c.Warn("Bad pattern '%s': %v", pattern, err)
+			return
+		}
+	}
+
+	c.markWhere(unmark, func(info *FileInfo) bool {
+		for _, pattern := range patterns {
+			if ok, _ := filepath.Match(pattern, info.Name()); ok {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// MarkRegex marks the entries whose name matches the regular expression.
+func (c *Controller) MarkRegex(args []string) {
+	if len(args) != 1 {
+		c.Warn("Usage: mark_regex <regex>")
+		return
+	}
+	re, err := regexp.Compile(args[0])
+	if err != nil {
+		c.Warn("%v", err)
+		return
+	}
+
+	c.markWhere(false, func(info *FileInfo) bool {
+		return re.MatchString(info.Name())
+	})
+}
+
+// MarkNewer marks the entries modified less than a duration ago, as for
+// time.ParseDuration, e.g. "90m".
+func (c *Controller) MarkNewer(args []string) {
+	if len(args) != 1 {
+		c.Warn("Usage: mark_newer <duration>")
+		return
+	}
+	d, err := time.ParseDuration(args[0])
+	if err != nil {
+		c.Warn("%v", err)
+		return
+	}
+
+	since := time.Now().Add(-d)
+	c.markWhere(false, func(info *FileInfo) bool {
+		return info.ModTime().After(since)
+	})
+}
+
+// OutputOrder is "output_order mark|path|listing", the order marks are
+// printed and given to %F in, and numbered by.
+func (c *Controller) OutputOrder(args []string) {
+	if len(args) != 1 {
+		c.Warn("Usage: output_order mark|path|listing")
+		return
 	}
+	order, err := ParseSelectionOrder(args[0])
+	if err != nil {
+		c.Warn("%v", err)
+		return
+	}
+	c.marks.SetOrder(order)
+	c.Draw()
 }
 
 func (c *Controller) Mark() {
@@ -248,6 +576,39 @@ func (c *Controller) ToggleMark() {
 	c.main.Draw()
 }
 
+// Visual starts or ends visual mode, the range in the main pane from the
+// entry selected. Marking marks the whole range then. It ends when cwd
+// changes, the range being in its listing.
+func (c *Controller) Visual(on bool) {
+	if on == c.main.Visual {
+		return
+	}
+	c.main.Visual = on
+	c.main.VisualAt = c.main.SelectAt
+	if on {
+		c.modes.Enter("visual")
+	} else {
+		c.modes.Leave()
+	}
+	c.main.Draw()
+}
+
+// MarkRange marks the entries from the one selected to the one at x, y,
+// which gets selected.
+func (c *Controller) MarkRange(x, y int) {
+	if !c.inMain(x, y) {
+		return
+	}
+	from := c.main.SelectAt
+	c.main.Select(y)
+	begin, end := from, c.main.SelectAt
+	if begin > end {
+		begin, end = end, begin
+	}
+	c.main.markRows(begin, end, false, false)
+	c.main.Draw()
+}
+
 func (c *Controller) inLeft(x, y int) bool {
 	return c.left.Win.In(x, y)
 }
@@ -256,6 +617,18 @@ func (c *Controller) inMain(x, y int) bool {
 	return c.main.Win.In(x, y)
 }
 
+func (c *Controller) inPreview(x, y int) bool {
+	return c.preview.Win != nil && c.preview.Win.In(x, y)
+}
+
+func (c *Controller) PreviewScrollDown(n int) {
+	if c.preview.Win == nil {
+		return
+	}
+	c.preview.ScrollDown(n)
+	c.preview.Draw()
+}
+
 func (c *Controller) Select(x, y int) {
 	if c.inLeft(x, y) {
 		c.left.Select(y)
@@ -292,20 +665,21 @@ func (c *Controller) saveListViewState() {
 }
 
 func (c *Controller) In() {
+	c.levelsIn = 0
 	info := c.main.List.GetFileInfo(c.main.SelectAt)
-	if info == nil || !(info.IsDir() || info.LinkState == LinkStateWorking) {
+	if info == nil || !(info.IsDir() || info.LinkState == LinkStateWorking || isArchive(info)) {
 		return
 	}
 
+	c.Visual(false)
 	c.saveListViewState()
 
 	newCwd := info.Path
-	if err := os.Chdir(newCwd); err != nil {
+	if err := c.fsys.Chdir(newCwd); err != nil {
 		c.cli.Warn("%+v", err)
 		return
 	}
-	c.dirs.Add(newCwd, c.dirInfoCMD)
-	c.dirs.Remove(c.parentCwd)
+	c.acquireDir(newCwd, c.dirInfoCMD)
 	c.parentCwd = c.cwd
 	c.cwd = newCwd
 	c.parentCwdInited = c.cwdInited
@@ -323,19 +697,46 @@ func (c *Controller) In() {
 	}
 }
 
+// InLevels goes in n times along the selection. The levels after the first
+// one are entered as their directories are read.
+func (c *Controller) InLevels(n int) {
+	cwd := c.cwd
+	c.In()
+	if c.cwd != cwd {
+		c.levelsIn = n - 1
+	}
+}
+
+// OutLevels goes out n times, selecting the way back in each.
+func (c *Controller) OutLevels(n int) {
+	if n <= 1 {
+		c.Out()
+		return
+	}
+
+	path := c.cwd
+	for i := 0; i < n && path != "/"; i++ {
+		path = filepath.Dir(path)
+	}
+	c.saveListViewState()
+	updateListViewStates(c.listViewStates, c.cwd)
+	c.Jump(path)
+}
+
 func (c *Controller) Out() {
+	c.levelsIn = 0
 	if c.cwd == "/" {
 		return
 	}
 
+	c.Visual(false)
 	c.saveListViewState()
 
-	if err := os.Chdir(c.parentCwd); err != nil {
+	if err := c.fsys.Chdir(c.parentCwd); err != nil {
 		c.cli.Warn("%+v", err)
 		return
 	}
 
-	c.dirs.Remove(c.cwd)
 	c.cwd = c.parentCwd
 	c.cwdInited = c.parentCwdInited
 	c.parentCwdInited = false
@@ -362,12 +763,182 @@ func (c *Controller) Out() {
 	}
 }
 
+// Jump goes to the directory path, or to the directory of path with it
+// selected if it is not a directory.
+func (c *Controller) Jump(path string) {
+	c.levelsIn = 0
+	path = filepath.Clean(path)
+	fi, err := c.fsys.Stat(path)
+	if err != nil {
+		c.cli.Warn("%+v", err)
+		return
+	}
+
+	if fi.IsDir() {
+		c.jump(path, "")
+	} else {
+		c.jump(filepath.Dir(path), filepath.Base(path))
+	}
+}
+
+// Reveal goes to the directory of path, selecting path there, also if it
+// is a directory.
+func (c *Controller) Reveal(path string) {
+	c.levelsIn = 0
+	path = filepath.Clean(path)
+	c.jump(filepath.Dir(path), filepath.Base(path))
+}
+
+// jump goes to the directory newCwd, selecting the entry named selected in
+// it unless empty.
+func (c *Controller) jump(newCwd, selected string) {
+	if newCwd == c.cwd {
+		if selected != "" {
+			c.selectName(selected)
+		}
+		return
+	}
+
+	if err := c.fsys.Chdir(newCwd); err != nil {
+		c.cli.Warn("%+v", err)
+		return
+	}
+
+	c.Visual(false)
+	c.saveListViewState()
+	updateListViewStates(c.listViewStates, newCwd)
+	if selected != "" {
+		c.listViewStates[newCwd] = listViewState{selected: selected}
+	}
+
+	c.cwd = newCwd
+	c.cwdInited = false
+	c.main.List.UpdateRows(nil)
+	c.main.SelectAt = 0
+	c.main.ViewBeginAt = 0
+	c.main.Draw()
+	c.acquireDir(c.cwd, append([]string{"reset_info"}, c.dirInfoCMD...))
+
+	c.parentCwd = ""
+	c.parentCwdInited = false
+	c.left.List.UpdateRows(nil)
+	c.left.SelectAt = 0
+	c.left.ViewBeginAt = 0
+	c.left.Draw()
+	if c.cwd != "/" {
+		c.parentCwd = filepath.Dir(c.cwd)
+		c.acquireDir(c.parentCwd, []string{"reset_info"})
+	}
+}
+
+func (c *Controller) Back() {
+	path, ok := c.history.Back()
+	if !ok {
+		return
+	}
+	c.Jump(path)
+	if c.cwd != path {
+		c.history.Forward()
+	}
+}
+
+func (c *Controller) Forward() {
+	path, ok := c.history.Forward()
+	if !ok {
+		return
+	}
+	c.Jump(path)
+	if c.cwd != path {
+		c.history.Back()
+	}
+}
+
+// ShowHistory lists the directories visited in this session, the most
+// recent first.
+func (c *Controller) ShowHistory(menu *Menu) CMD {
+	return func() {
+		h := &c.history
+		items := make([]MenuItem, 0, len(h.entries))
+		for i := len(h.entries) - 1; i >= 0; i-- {
+			item := MenuItem{
+				Text:  h.entries[i],
+				Value: h.entries[i],
+			}
+			if i == h.pos {
+				item.Info = "current"
+			}
+			items = append(items, item)
+		}
+
+		menu.Open("History", items, func(item MenuItem) {
+			c.Jump(item.Value)
+		}, nil)
+	}
+}
+
+// expandPath expands ~ and environment variables in path and makes it
+// absolute, relative to cwd.
+func expandPath(cwd, path string) string {
+	path = os.ExpandEnv(replaceTilde(path))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cwd, path)
+	}
+	return filepath.Clean(path)
+}
+
+// Cd goes to the directory given as arguments, home without them, or back
+// with "-".
+func (c *Controller) Cd(args []string) {
+	path := strings.Join(args, " ")
+	switch path {
+	case "":
+		path = UserHomeDir
+	case "-":
+		c.Back()
+		return
+	}
+	c.Jump(expandPath(c.cwd, path))
+}
+
+// JumpPaste jumps to the path pasted, e.g. copied from another terminal.
+// Quotes around it are dropped.
+func (c *Controller) JumpPaste(ev Event, _ *map[Event][]Action, _ []string) {
+	path := strings.TrimSpace(ev.Text)
+	if strings.ContainsAny(path, "\r\n") {
+		c.cli.Warn("Pasted more than one line")
+		return
+	}
+	if len(path) >= 2 && (path[0] == '\'' || path[0] == '"') && path[len(path)-1] == path[0] {
+		path = path[1 : len(path)-1]
+	}
+	if path == "" {
+		return
+	}
+	c.Jump(expandPath(c.cwd, path))
+}
+
+func (c *Controller) selectName(name string) {
+	for i := 0; i < c.main.List.Size(); i++ {
+		if c.main.List.GetFileInfo(i).Name() == name {
+			c.main.SelectAt = i
+			c.main.Draw()
+			return
+		}
+	}
+}
+
 func (c *Controller) Quit() {
 	panic(0)
 }
 
 func (c *Controller) HandleDirEvent(event DirEvent) {
 	if event.Err != nil {
+		if event.Path == c.preview.Path {
+			c.preview.IsDir = false
+			c.preview.Err = event.Err
+			c.preview.Draw()
+			return
+		}
 		c.cli.Warn("%+v", event.Err)
 	}
 
@@ -381,6 +952,16 @@ func (c *Controller) HandleDirEvent(event DirEvent) {
 			} else {
 				c.main.SelectAt = 0
 			}
+
+			if c.levelsIn > 0 {
+				levels := c.levelsIn
+				c.main.Draw()
+				c.InLevels(levels)
+				return
+			}
+		} else if c.reselect != "" && filepath.Dir(c.reselect) == c.cwd {
+			c.main.SelectAt = findInRow(event, filepath.Base(c.reselect))
+			c.reselect = ""
 		}
 		c.main.Draw()
 	} else if event.Path == c.parentCwd {
@@ -389,9 +970,33 @@ func (c *Controller) HandleDirEvent(event DirEvent) {
 		cwdBase := filepath.Base(c.cwd)
 		c.left.SelectAt = findInRow(event, cwdBase)
 		c.left.Draw()
+	} else if event.Path == c.preview.Path && c.preview.IsDir {
+		c.preview.List.List.UpdateRows(event.Rows)
+		if state, ok := c.listViewStates[event.Path]; ok {
+			c.preview.List.SelectAt = findInRow(event, state.selected)
+		}
+		c.preview.Draw()
 	}
 }
 
+func (c *Controller) HandlePreviewEvent(event PreviewEvent) {
+	if event.Path != c.preview.Path {
+		// Selection has moved on
+		return
+	}
+
+	if event.IsDir {
+		c.preview.IsDir = true
+		c.acquireDir(event.Path, []string{"reset_info"})
+		return
+	}
+
+	c.preview.Lines = event.Lines
+	c.preview.Binary = event.Binary
+	c.preview.Err = event.Err
+	c.preview.Draw()
+}
+
 func findInRow(event DirEvent, s string) int {
 	for i, row := range event.Rows {
 		if row.FileInfo.Name() == s {
@@ -403,16 +1008,26 @@ func findInRow(event DirEvent, s string) int {
 
 func (c *Controller) HandleMouseEvent(ev Event, keymap *map[Event][]Action, args []string) {
 	me := ev.MouseEvent
+	if me == nil {
+		return
+	}
 	if me.S != 0 {
 		// Scroll
+		if c.inPreview(me.X, me.Y) {
+			c.PreviewScrollDown(-me.S * 3)
+			return
+		}
 		c.ScrollDown(-me.S*3, c.inLeft(me.X, me.Y))
 		return
 	}
 	if me.Down {
 		var e Event
-		if me.Left {
+		switch {
+		case me.Left && me.Shift:
+			e = ShiftClick.AsEvent()
+		case me.Left:
 			e = LeftClick.AsEvent()
-		} else {
+		default:
 			e = RightClick.AsEvent()
 		}
 
@@ -429,6 +1044,10 @@ func (c *Controller) HandleMouseEvent(ev Event, keymap *map[Event][]Action, args
 }
 
 func (c *Controller) ToggleDirInfo(cmds []string) {
+	if err := checkDirInfoColumns(cmds); err != nil {
+		c.Warn("%v", err)
+		return
+	}
 	if c.dirInfoCMD != nil {
 		c.dirInfoCMD = nil
 	} else {
@@ -440,7 +1059,24 @@ func (c *Controller) ToggleDirInfo(cmds []string) {
 	}
 }
 
-func (c *Controller) DirDo(cmds []string) {
+// Filter shows only the entries of cwd matching pattern, all of them if it
+// is empty. The entry selected stays selected if it is still shown.
+func (c *Controller) Filter(pattern string) {
+	if info := c.Selected(); info != nil {
+		c.reselect = info.Path
+	}
+	if dir := c.dirs.Get(c.cwd); dir != nil {
+		dir.Do([]string{"filter " + pattern})
+	}
+}
+
+// DirDo runs the dir command, its arguments parsed by ParseDirCMDs.
+func (c *Controller) DirDo(args []string) {
+	cmds, err := ParseDirCMDs(args)
+	if err != nil {
+		c.Warn("%v", err)
+		return
+	}
 	if dir := c.dirs.Get(c.cwd); dir != nil {
 		dir.Do(cmds)
 	}
@@ -450,6 +1086,14 @@ func (c *Controller) Warn(format string, a ...any) {
 	c.cli.Warn(format, a...)
 }
 
+func (c *Controller) Notify(format string, a ...any) {
+	c.cli.Notify(format, a...)
+}
+
+func (c *Controller) Pending(keys string) {
+	c.cli.Pending(keys)
+}
+
 func (c *Controller) CMD(s string, cursor int) {
 	c.cli.CMD(s, cursor)
 }
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// newTestFS returns a small tree to navigate:
+//
+//	/home/u/docs/a.txt  3 bytes
+//	/home/u/docs/B.txt  1 byte
+//	/home/u/src/
+//	/home/u/notes.md
+//	/home/u/link -> docs
+func newTestFS(t *testing.T) *MemFS {
+	t.Helper()
+	m := NewMemFS()
+	for _, err := range []error{
+		m.MkdirAll("/home/u/docs", 0755),
+		m.MkdirAll("/home/u/src", 0755),
+		m.WriteFile("/home/u/docs/a.txt", []byte("aaa"), 0644),
+		m.WriteFile("/home/u/docs/B.txt", []byte("b"), 0644),
+		m.WriteFile("/home/u/notes.md", []byte("notes"), 0644),
+		m.Symlink("docs", "/home/u/link"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+// newTestController returns a Controller on a simulated screen, in cwd
+// once it is listed.
+func newTestController(t *testing.T, fsys FS, cwd string) *Controller {
+	t.Helper()
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	screen.SetSize(80, 24)
+	t.Cleanup(screen.Fini)
+
+	if err := fsys.Chdir(cwd); err != nil {
+		t.Fatal(err)
+	}
+	dirs := NewDirSet(fsys, StyleM)
+	t.Cleanup(func() { dirs.Retain() })
+	c := NewController(fsys, cwd, dirs, NewPreviewer(fsys, "", time.Second), NewSelection(), screen)
+	settle(t, c)
+	return c
+}
+
+// settle handles the events of the directories until the current one and
+// its parent are listed.
+func settle(t *testing.T, c *Controller) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for !c.cwdInited || (c.parentCwd != "" && !c.parentCwdInited) {
+		select {
+		case ev := <-c.dirs.Event():
+			c.HandleDirEvent(ev)
+		case <-timeout:
+			t.Fatalf("%s is not listed", c.cwd)
+		}
+	}
+	c.Show()
+}
+
+func listedNames(l *List) []string {
+	var names []string
+	for i := 0; i < l.Size(); i++ {
+		names = append(names, l.GetFileInfo(i).Name())
+	}
+	return names
+}
+
+func selectName(t *testing.T, c *Controller, name string) {
+	t.Helper()
+	c.selectName(name)
+	if info := c.Selected(); info == nil || info.Name() != name {
+		t.Fatalf("cannot select %s in %s", name, c.cwd)
+	}
+}
+
+func TestControllerListing(t *testing.T) {
+	c := newTestController(t, newTestFS(t), "/home/u")
+
+	if got, want := listedNames(&c.main.List), []string{"docs", "link", "notes.md", "src"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("main lists %v, want %v", got, want)
+	}
+	if got, want := listedNames(&c.left.List), []string{"u"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("left lists %v, want %v", got, want)
+	}
+}
+
+func TestControllerInOut(t *testing.T) {
+	c := newTestController(t, newTestFS(t), "/home/u")
+
+	selectName(t, c, "docs")
+	c.In()
+	settle(t, c)
+	if c.cwd != "/home/u/docs" {
+		t.Fatalf("in went to %s", c.cwd)
+	}
+	if got, want := listedNames(&c.main.List), []string{"a.txt", "B.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("docs lists %v, want %v", got, want)
+	}
+	if info := c.left.List.GetFileInfo(c.left.SelectAt); info == nil || info.Name() != "docs" {
+		t.Errorf("left pane selects %v, want docs", info)
+	}
+
+	c.Out()
+	settle(t, c)
+	if c.cwd != "/home/u" {
+		t.Fatalf("out went to %s", c.cwd)
+	}
+	if info := c.Selected(); info == nil || info.Name() != "docs" {
+		t.Errorf("out selects %v, want docs", info)
+	}
+
+	// Files are not entered
+	selectName(t, c, "notes.md")
+	c.In()
+	if c.cwd != "/home/u" {
+		t.Errorf("in on a file went to %s", c.cwd)
+	}
+}
+
+func TestControllerInLink(t *testing.T) {
+	c := newTestController(t, newTestFS(t), "/home/u")
+
+	selectName(t, c, "link")
+	if info := c.Selected(); info.LinkState != LinkStateWorking || info.LinkTarget != "docs" {
+		t.Errorf("link has state %v and target %q", info.LinkState, info.LinkTarget)
+	}
+	c.In()
+	settle(t, c)
+	if c.cwd != "/home/u/link" {
+		t.Fatalf("in went to %s", c.cwd)
+	}
+	if got, want := listedNames(&c.main.List), []string{"a.txt", "B.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("link lists %v, want %v", got, want)
+	}
+}
+
+func TestControllerJumpHistory(t *testing.T) {
+	c := newTestController(t, newTestFS(t), "/home/u")
+
+	c.Jump("/home/u/docs/B.txt")
+	settle(t, c)
+	if c.cwd != "/home/u/docs" {
+		t.Fatalf("jump to a file went to %s", c.cwd)
+	}
+	if info := c.Selected(); info == nil || info.Name() != "B.txt" {
+		t.Errorf("jump selects %v, want B.txt", info)
+	}
+
+	c.Jump("/home/u/src")
+	settle(t, c)
+	if c.cwd != "/home/u/src" || c.main.List.Size() != 0 {
+		t.Fatalf("jump went to %s listing %v", c.cwd, listedNames(&c.main.List))
+	}
+
+	c.Back()
+	settle(t, c)
+	if c.cwd != "/home/u/docs" {
+		t.Errorf("back went to %s", c.cwd)
+	}
+	c.Forward()
+	settle(t, c)
+	if c.cwd != "/home/u/src" {
+		t.Errorf("forward went to %s", c.cwd)
+	}
+}
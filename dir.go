@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"fmt"
 	"log"
-	"os"
 	"os/exec"
 	"sort"
 	"strings"
@@ -21,37 +20,38 @@ func sortBySize(files []*FileInfo) {
 }
 
 func sortByName(files []*FileInfo) {
-	lessLower := func(sa, sb string) bool {
-		for {
-			rb, nb := utf8.DecodeRuneInString(sb)
-			if nb == 0 {
-				// The number of runes in sa is greater than or
-				// equal to the number of runes in sb. It follows
-				// that sa is not less than sb.
-				return false
-			}
+	sort.Slice(files, func(i, j int) bool { return lessLower(files[i].Name(), files[j].Name()) })
+}
 
-			ra, na := utf8.DecodeRuneInString(sa)
-			if na == 0 {
-				// The number of runes in sa is less than the
-				// number of runes in sb. It follows that sa
-				// is less than sb.
-				return true
-			}
+func lessLower(sa, sb string) bool {
+	for {
+		rb, nb := utf8.DecodeRuneInString(sb)
+		if nb == 0 {
+			// The number of runes in sa is greater than or
+			// equal to the number of runes in sb. It follows
+			// that sa is not less than sb.
+			return false
+		}
 
-			rb = unicode.ToLower(rb)
-			ra = unicode.ToLower(ra)
+		ra, na := utf8.DecodeRuneInString(sa)
+		if na == 0 {
+			// The number of runes in sa is less than the
+			// number of runes in sb. It follows that sa
+			// is less than sb.
+			return true
+		}
 
-			if ra != rb {
-				return ra < rb
-			}
+		rb = unicode.ToLower(rb)
+		ra = unicode.ToLower(ra)
 
-			// Trim rune from the beginning of each string.
-			sa = sa[na:]
-			sb = sb[nb:]
+		if ra != rb {
+			return ra < rb
 		}
+
+		// Trim rune from the beginning of each string.
+		sa = sa[na:]
+		sb = sb[nb:]
 	}
-	sort.Slice(files, func(i, j int) bool { return lessLower(files[i].Name(), files[j].Name()) })
 }
 
 type DirEvent struct {
@@ -61,13 +61,15 @@ type DirEvent struct {
 }
 
 type DirSet struct {
+	fsys    FS
 	dirs    []*Dir
 	eventCh chan DirEvent
 	styles  StyleMap
 }
 
-func NewDirSet(styles StyleMap) *DirSet {
+func NewDirSet(fsys FS, styles StyleMap) *DirSet {
 	return &DirSet{
+		fsys:    fsys,
 		eventCh: make(chan DirEvent, 1),
 		styles:  styles,
 	}
@@ -76,7 +78,7 @@ func NewDirSet(styles StyleMap) *DirSet {
 func (c *DirSet) Add(path string, cmds []string) {
 	_, ok := c.find(path)
 	if !ok {
-		dir := NewDir(path, c.styles, c.eventCh)
+		dir := NewDir(c.fsys, path, c.styles, c.eventCh)
 		go dir.Run(cmds)
 		c.dirs = append(c.dirs, dir)
 	}
@@ -90,6 +92,28 @@ func (c *DirSet) Remove(path string) {
 	}
 }
 
+// Retain removes every dir whose path is not one of paths.
+func (c *DirSet) Retain(paths ...string) {
+	kept := c.dirs[:0]
+	for _, dir := range c.dirs {
+		if containsString(paths, dir.path) {
+			kept = append(kept, dir)
+		} else {
+			dir.Fini()
+		}
+	}
+	c.dirs = kept
+}
+
+func containsString(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *DirSet) find(path string) (int, bool) {
 	for i, dir := range c.dirs {
 		if dir.path == path {
@@ -159,6 +183,7 @@ const (
 )
 
 type Dir struct {
+	fsys          FS
 	eventCh       chan<- DirEvent
 	path          string
 	sort          func([]*FileInfo)
@@ -176,8 +201,9 @@ type Dir struct {
 	timeColumn       timeColumnFormat
 }
 
-func NewDir(path string, styles StyleMap, eventCh chan<- DirEvent) *Dir {
+func NewDir(fsys FS, path string, styles StyleMap, eventCh chan<- DirEvent) *Dir {
 	return &Dir{
+		fsys:    fsys,
 		eventCh: eventCh,
 		path:    path,
 		styles:  styles,
@@ -191,6 +217,9 @@ func (d *Dir) Run(cmds []string) {
 			Path: d.path,
 			Err:  err,
 		}
+		// Keep Do from blocking until Fini
+		for range d.cmdCh {
+		}
 		return
 	}
 
@@ -210,6 +239,48 @@ func (d *Dir) Do(cmds []string) {
 	d.cmdCh <- cmds
 }
 
+// dirInfoColumns are the commands of Dir.Do that show a column of the info.
+var dirInfoColumns = []string{
+	"perm", "user_name", "group_name", "user_group_name", "link_target",
+	"link_count", "hsize", "size", "atime", "ctime", "mtime",
+}
+
+// dirCMDs are all the commands of Dir.Do.
+var dirCMDs = append([]string{
+	"filter", "sort_by_size", "no_perm", "no_link_target", "no_link_count",
+	"no_size", "no_user", "no_time", "reset_info",
+}, dirInfoColumns...)
+
+// ParseDirCMDs turns the arguments of the dir command into commands of
+// Dir.Do. The arguments after "filter" make up its pattern, so that both
+// "dir filter a b" and "dir 'filter a b'" filter by "a b".
+func ParseDirCMDs(args []string) ([]string, error) {
+	cmds := make([]string, 0, len(args))
+	for i, arg := range args {
+		name := strings.SplitN(arg, " ", 2)[0]
+		if !containsString(dirCMDs, name) {
+			return nil, fmt.Errorf("unknown dir command '%s'", name)
+		}
+		if name == "filter" {
+			cmds = append(cmds, strings.Join(args[i:], " "))
+			break
+		}
+		cmds = append(cmds, arg)
+	}
+	return cmds, nil
+}
+
+// checkDirInfoColumns reports the first of columns that is not one of
+// dirInfoColumns.
+func checkDirInfoColumns(columns []string) error {
+	for _, column := range columns {
+		if !containsString(dirInfoColumns, column) {
+			return fmt.Errorf("unknown info column '%s'", column)
+		}
+	}
+	return nil
+}
+
 func (d *Dir) do(cmds []string) {
 	var shouldSort bool
 	for _, cmd := range cmds {
@@ -333,20 +404,14 @@ func (d *Dir) init() error {
 		d.sort = sortByName
 	}
 
-	f, err := os.Open(d.path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	fiList, err := f.Readdir(-1)
+	fiList, err := d.fsys.ReadDir(d.path)
 	if err != nil {
 		return err
 	}
 
 	files := make([]*FileInfo, 0, len(fiList))
 	for _, fi := range fiList {
-		files = append(files, NewFileInfo(fi, d.path))
+		files = append(files, NewFileInfo(d.fsys, fi, d.path))
 	}
 	d.sort(files)
 
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// runTestDir runs a Dir listing path in fsys, returning a function that
+// waits for the next rows it sends.
+func runTestDir(t *testing.T, fsys FS, path string) (*Dir, func() DirEvent) {
+	t.Helper()
+	ch := make(chan DirEvent, 1)
+	d := NewDir(fsys, path, StyleM, ch)
+	go d.Run(nil)
+	t.Cleanup(d.Fini)
+
+	next := func() DirEvent {
+		t.Helper()
+		select {
+		case ev := <-ch:
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s is not listed", path)
+		}
+		return DirEvent{}
+	}
+	return d, next
+}
+
+func rowNames(rows []ListRow) []string {
+	var names []string
+	for _, row := range rows {
+		names = append(names, row.FileInfo.Name())
+	}
+	return names
+}
+
+func TestDirListing(t *testing.T) {
+	d, next := runTestDir(t, newTestFS(t), "/home/u/docs")
+
+	ev := next()
+	if ev.Err != nil {
+		t.Fatal(ev.Err)
+	}
+	if got, want := rowNames(ev.Rows), []string{"a.txt", "B.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("listed %v, want %v", got, want)
+	}
+	if path := ev.Rows[0].FileInfo.Path; path != "/home/u/docs/a.txt" {
+		t.Errorf("path of a.txt is %s", path)
+	}
+
+	d.Do([]string{"sort_by_size"})
+	if got, want := rowNames(next().Rows), []string{"B.txt", "a.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sorted by size %v, want %v", got, want)
+	}
+}
+
+func TestDirListingError(t *testing.T) {
+	_, next := runTestDir(t, newTestFS(t), "/home/u/notes.md")
+	if ev := next(); ev.Err == nil {
+		t.Errorf("listing a file sent %v", rowNames(ev.Rows))
+	}
+}
+
+func TestParseDirCMDs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+		ok   bool
+	}{
+		{nil, []string{}, true},
+		{[]string{"sort_by_size", "mtime"}, []string{"sort_by_size", "mtime"}, true},
+		{[]string{"filter"}, []string{"filter"}, true},
+		{[]string{"filter", "a", "b"}, []string{"filter a b"}, true},
+		{[]string{"filter a b"}, []string{"filter a b"}, true},
+		{[]string{"mtime", "filter", "size"}, []string{"mtime", "filter size"}, true},
+		{[]string{"bogus"}, nil, false},
+		{[]string{"mtime", "bogus"}, nil, false},
+	}
+	for _, test := range tests {
+		got, err := ParseDirCMDs(test.args)
+		if (err == nil) != test.ok || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseDirCMDs(%q) = %q, %v", test.args, got, err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	// Counts are capped, so that typing many digits cannot overflow them
+	dispatchMaxCount = 999999
+)
+
+// Dispatcher runs the actions bound to the keys typed. Keys starting a
+// sequence bound in the keymap are held until the sequence is complete.
+// If the sequence does not go on within timeout, the keys held run if they
+// are bound themselves, and are dropped otherwise. Digits typed before a
+// key that are not bound themselves make up a count for it, passed in
+// Event.Count.
+type Dispatcher struct {
+	c       *Controller
+	keymap  *map[Event][]Action
+	timeout time.Duration
+
+	count   int
+	pending []Event
+	timer   *time.Timer
+}
+
+func NewDispatcher(c *Controller, keymap *map[Event][]Action, timeout time.Duration) *Dispatcher {
+	return &Dispatcher{
+		c:       c,
+		keymap:  keymap,
+		timeout: timeout,
+	}
+}
+
+// Timeout fires when the keys held should run. It is nil when no key is
+// held, which blocks forever in a select.
+func (d *Dispatcher) Timeout() <-chan time.Time {
+	if d.timer == nil {
+		return nil
+	}
+	return d.timer.C
+}
+
+func (d *Dispatcher) Dispatch(ev Event) {
+	switch ev.Type {
+	case Resize:
+		HandleAction(ev, d.keymap)
+		return
+	case Mouse, Paste:
+		d.reset()
+		HandleAction(ev, d.keymap)
+		return
+	case ESC, CtrlC:
+		if len(d.pending) != 0 || d.count != 0 {
+			d.reset()
+			return
+		}
+	}
+
+	keymap := *d.keymap
+	if d.isCount(keymap, ev) {
+		d.count = d.count*10 + int(ev.Char-'0')
+		if d.count > dispatchMaxCount {
+			d.count = dispatchMaxCount
+		}
+		d.show()
+		return
+	}
+
+	keys := append(d.pending[:len(d.pending):len(d.pending)], ev.Comparable())
+	if _, ok := keymap[prefixEvent(keys)]; ok {
+		d.hold(keys)
+		return
+	}
+
+	ev.Count = d.count
+	d.reset()
+	if len(keys) == 1 {
+		HandleAction(ev, d.keymap)
+		return
+	}
+	actions, ok := keymap[seqEvent(keys)]
+	if !ok {
+		d.c.Warn("'%s' is not bound", keysName(keys))
+		return
+	}
+	for _, action := range actions {
+		action(ev, d.keymap)
+	}
+}
+
+// Flush runs the keys held, as bound so far, when the timeout fires.
+func (d *Dispatcher) Flush() {
+	keys, count := d.pending, d.count
+	d.reset()
+	if len(keys) == 0 {
+		return
+	}
+	ev := keys[len(keys)-1]
+	ev.Count = count
+	for _, action := range (*d.keymap)[seqEvent(keys)] {
+		action(ev, d.keymap)
+	}
+}
+
+// isCount reports whether ev is a digit of a count. A 0 only goes on a
+// count, and a digit that is bound, also as any rune, is not one.
+func (d *Dispatcher) isCount(keymap map[Event][]Action, ev Event) bool {
+	if len(d.pending) != 0 || ev.Type != Rune || ev.Char < '0' || ev.Char > '9' {
+		return false
+	}
+	if ev.Char == '0' && d.count == 0 {
+		return false
+	}
+	for _, key := range []Event{ev.Comparable(), Rune.AsEvent(), prefixEvent([]Event{ev.Comparable()})} {
+		if _, ok := keymap[key]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *Dispatcher) hold(keys []Event) {
+	d.pending = keys
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.timer = time.NewTimer(d.timeout)
+	d.show()
+}
+
+func (d *Dispatcher) show() {
+	s := keysName(d.pending)
+	if d.count != 0 {
+		s = strconv.Itoa(d.count) + s
+	}
+	d.c.Pending(s)
+}
+
+func (d *Dispatcher) reset() {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if len(d.pending) != 0 || d.count != 0 {
+		d.pending = nil
+		d.count = 0
+		d.c.Pending("")
+	}
+}
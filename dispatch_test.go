@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDispatcher(t *testing.T) {
+	c := newTestController(t, newTestFS(t), "/home/u")
+
+	var ran []string
+	record := func(name string) CMD {
+		return func(count int, args []string) {
+			ran = append(ran, fmt.Sprintf("%s%d", name, count))
+		}
+	}
+	cmds := map[string]CMD{
+		"next": record("next"),
+		"top":  record("top"),
+		"go":   record("go"),
+		"two":  record("two"),
+	}
+	bindings := NewBindings(c, make(map[Event][]Action), cmds)
+	if err := bindings.Parse("j:next,g g:top,g h:go,G:go,2:two,z z z:top"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		keys string
+		// Whether the keys held run as on a timeout
+		flush bool
+		want  string
+	}{
+		{"j", false, "next0"},
+		{"5 j", false, "next5"},
+		{"1 0 j", false, "next10"},
+		{"1 3 j", false, "next13"},
+		{"0 j", false, "next0"},
+		{"9 9 9 9 9 9 9 9 j", false, "next999999"},
+		{"g g", false, "top0"},
+		{"3 g g", false, "top3"},
+		{"g h j", false, "go0 next0"},
+		// Not bound as a whole
+		{"g x j", false, "next0"},
+		{"g j", false, ""},
+		// Bound digits are not counts, and end one
+		{"2 j", false, "two0 next0"},
+		{"1 2 j", false, "two1 next0"},
+		{"5 esc j", false, "next0"},
+		{"g esc j", false, "next0"},
+		{"z z", false, ""},
+		{"z z", true, ""},
+		{"z z z", false, "top0"},
+	}
+	for _, test := range tests {
+		keys, err := parseKeys(test.keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ran = nil
+		d := NewDispatcher(c, &bindings.keymap, time.Hour)
+		for _, key := range keys {
+			d.Dispatch(key)
+		}
+		if test.flush {
+			if d.Timeout() == nil {
+				t.Errorf("%s: no keys held", test.keys)
+			}
+			d.Flush()
+		}
+		if got := strings.Join(ran, " "); got != test.want {
+			t.Errorf("%s: ran %q, want %q", test.keys, got, test.want)
+		}
+		d.reset()
+	}
+}
+
+func TestDispatcherFlush(t *testing.T) {
+	c := newTestController(t, newTestFS(t), "/home/u")
+
+	var ran []int
+	cmds := map[string]CMD{
+		"prefix": func(count int, _ []string) { ran = append(ran, count) },
+		"seq":    func() { t.Error("g g ran") },
+	}
+	bindings := NewBindings(c, make(map[Event][]Action), cmds)
+	if err := bindings.Parse("g:prefix,g g:seq"); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDispatcher(c, &bindings.keymap, time.Millisecond)
+	for _, key := range []Event{Key('4'), Key('g')} {
+		d.Dispatch(key)
+	}
+	if len(ran) != 0 {
+		t.Fatal("g ran before the timeout")
+	}
+
+	select {
+	case <-d.Timeout():
+		d.Flush()
+	case <-time.After(5 * time.Second):
+		t.Fatal("no timeout")
+	}
+	if want := []int{4}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran g with counts %v, want %v", ran, want)
+	}
+	if d.Timeout() != nil {
+		t.Error("keys still held")
+	}
+}
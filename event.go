@@ -4,6 +4,10 @@ type Event struct {
 	Type       EventType
 	Char       rune
 	MouseEvent *MouseEvent
+	// Of Paste
+	Text string
+	// Typed before the key, 0 if none
+	Count int
 }
 
 type MouseEvent struct {
@@ -14,6 +18,7 @@ type MouseEvent struct {
 	Down   bool
 	Double bool
 	Mod    bool
+	Shift  bool
 }
 
 // Types of user action
@@ -63,6 +68,12 @@ const (
 	DoubleClick
 	LeftClick
 	RightClick
+	ShiftClick
+	Paste
+
+	// Of bindings of key sequences, see bindKeys
+	KeySeq
+	KeyPrefix
 
 	BTab
 	BSpace
@@ -113,12 +124,15 @@ const (
 	AltSLeft
 	AltSRight
 
+	CtrlLeft
+	CtrlRight
+
 	Alt
 	CtrlAlt
 )
 
 func (t EventType) AsEvent() Event {
-	return Event{t, 0, nil}
+	return Event{Type: t}
 }
 
 func (t EventType) Int() int {
@@ -130,19 +144,19 @@ func (t EventType) Byte() byte {
 }
 
 func (e Event) Comparable() Event {
-	// Ignore MouseEvent pointer
-	return Event{e.Type, e.Char, nil}
+	// Ignore MouseEvent pointer and pasted text
+	return Event{Type: e.Type, Char: e.Char}
 }
 
 func Key(r rune) Event {
-	return Event{Rune, r, nil}
+	return Event{Type: Rune, Char: r}
 }
 
 func AltKey(r rune) Event {
-	return Event{Alt, r, nil}
+	return Event{Type: Alt, Char: r}
 }
 
 func CtrlAltKey(r rune) Event {
-	return Event{CtrlAlt, r, nil}
+	return Event{Type: CtrlAlt, Char: r}
 }
 
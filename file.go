@@ -3,13 +3,10 @@ package main
 import (
 	"fmt"
 	"io/fs"
-	"os"
 	"os/user"
 	"path/filepath"
-	"syscall"
 	"time"
 
-	"github.com/djherbis/times"
 	"github.com/gdamore/tcell/v2"
 )
 
@@ -28,32 +25,19 @@ type FileInfo struct {
 	LinkTarget string
 	Ext        string
 
-	accessTime time.Time
-	changeTime time.Time
+	attr FileAttr
 }
 
-func NewFileInfo(info fs.FileInfo, dir string) *FileInfo {
-	ts := times.Get(info)
-	at := ts.AccessTime()
-	var ct time.Time
-	// from times docs: ChangeTime() panics unless HasChangeTime() is true
-	if ts.HasChangeTime() {
-		ct = ts.ChangeTime()
-	} else {
-		// fall back to ModTime if ChangeTime cannot be determined
-		ct = info.ModTime()
-	}
-
+func NewFileInfo(fsys FS, info fs.FileInfo, dir string) *FileInfo {
 	fi := &FileInfo{
-		FileInfo:   info,
-		Path:       filepath.Join(dir, info.Name()),
-		Ext:        filepath.Ext(info.Name()),
-		accessTime: at,
-		changeTime: ct,
+		FileInfo: info,
+		Path:     filepath.Join(dir, info.Name()),
+		Ext:      filepath.Ext(info.Name()),
+		attr:     fsys.Attr(info),
 	}
 
 	if info.Mode()&fs.ModeSymlink != 0 {
-		link, err := os.Readlink(fi.Path)
+		link, err := fsys.Readlink(fi.Path)
 		if err != nil {
 			fi.LinkState = LinkStateBroken
 		} else {
@@ -107,8 +91,11 @@ func (i *FileInfo) ANSIMode(style tcell.Style) ListItem {
 }
 
 func (i *FileInfo) UserName() string {
-	if stat, ok := i.Sys().(*syscall.Stat_t); ok {
-		if u, err := user.LookupId(fmt.Sprint(stat.Uid)); err == nil {
+	if i.attr.User != "" {
+		return fmt.Sprintf("%v ", i.attr.User)
+	}
+	if i.attr.Uid != "" {
+		if u, err := user.LookupId(i.attr.Uid); err == nil {
 			return fmt.Sprintf("%v ", u.Username)
 		}
 	}
@@ -116,8 +103,11 @@ func (i *FileInfo) UserName() string {
 }
 
 func (i *FileInfo) GroupName() string {
-	if stat, ok := i.Sys().(*syscall.Stat_t); ok {
-		if g, err := user.LookupGroupId(fmt.Sprint(stat.Gid)); err == nil {
+	if i.attr.Group != "" {
+		return fmt.Sprintf("%v ", i.attr.Group)
+	}
+	if i.attr.Gid != "" {
+		if g, err := user.LookupGroupId(i.attr.Gid); err == nil {
 			return fmt.Sprintf("%v ", g.Name)
 		}
 	}
@@ -125,8 +115,8 @@ func (i *FileInfo) GroupName() string {
 }
 
 func (i *FileInfo) LinkCount() string {
-	if stat, ok := i.Sys().(*syscall.Stat_t); ok {
-		return fmt.Sprintf("%v ", stat.Nlink)
+	if i.attr.Links != 0 {
+		return fmt.Sprintf("%v ", i.attr.Links)
 	}
 	return ""
 }
@@ -172,9 +162,9 @@ func humanize(size int64) string {
 }
 
 func (i *FileInfo) AccessTime() time.Time {
-	return i.accessTime
+	return i.attr.AccessTime
 }
 
 func (i *FileInfo) ChangeTime() time.Time {
-	return i.changeTime
+	return i.attr.ChangeTime
 }
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// Ranks are aged when their sum goes over this, as z does.
+	frecencyMaxRank = 9000
+	// Visits are saved at most this often, and on exit
+	frecencySaveInterval = time.Minute
+	// Of the db.zo of zoxide 0.8 on, see importZoxide
+	zoxideVersion = 3
+)
+
+type frecencyEntry struct {
+	path string
+	rank float64
+	time time.Time
+}
+
+// score weights the rank of e by how recently it was visited.
+func (e *frecencyEntry) score(now time.Time) float64 {
+	switch age := now.Sub(e.time); {
+	case age < time.Hour:
+		return e.rank * 4
+	case age < 24*time.Hour:
+		return e.rank * 2
+	case age < 7*24*time.Hour:
+		return e.rank / 2
+	default:
+		return e.rank / 4
+	}
+}
+
+// Frecency ranks directories by how often and how recently they were
+// visited. It is saved in the format of z, "path|rank|time" per line.
+type Frecency struct {
+	path    string
+	entries map[string]*frecencyEntry
+	dirty   bool      // The entries changed since they were saved
+	saved   time.Time // When they were last saved
+}
+
+func LoadFrecency(path string) (*Frecency, error) {
+	f := &Frecency{
+		path:    path,
+		entries: make(map[string]*frecencyEntry),
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return f, err
+	}
+	defer file.Close()
+
+	err = f.importZ(file)
+	f.dirty = false
+	return f, err
+}
+
+// Save writes the database if it changed since it was last saved.
+func (f *Frecency) Save() error {
+	if !f.dirty {
+		return nil
+	}
+
+	var sb strings.Builder
+	for _, e := range f.sorted() {
+		fmt.Fprintf(&sb, "%s|%g|%d\n", e.path, e.rank, e.time.Unix())
+	}
+
+	if err := writeFileAtomic(f.path, []byte(sb.String())); err != nil {
+		return err
+	}
+	f.dirty = false
+	f.saved = time.Now()
+	return nil
+}
+
+// add merges a visit, or an imported entry, into the database.
+func (f *Frecency) add(path string, rank float64, t time.Time) {
+	e, ok := f.entries[path]
+	if !ok {
+		e = &frecencyEntry{path: path}
+		f.entries[path] = e
+	}
+	e.rank += rank
+	if t.After(e.time) {
+		e.time = t
+	}
+	f.dirty = true
+}
+
+func (f *Frecency) age() {
+	var sum float64
+	for _, e := range f.entries {
+		sum += e.rank
+	}
+	if sum <= frecencyMaxRank {
+		return
+	}
+
+	for path, e := range f.entries {
+		e.rank *= 0.99 * frecencyMaxRank / sum
+		if e.rank < 1 {
+			delete(f.entries, path)
+		}
+	}
+}
+
+// Visit counts a visit to path. It is saved at once only if the database
+// was not saved for frecencySaveInterval, Save has to be called on exit.
+func (f *Frecency) Visit(path string) error {
+	now := time.Now()
+	f.add(path, 1, now)
+	f.age()
+	if now.Sub(f.saved) < frecencySaveInterval {
+		return nil
+	}
+	return f.Save()
+}
+
+// sorted returns the entries, best score first.
+func (f *Frecency) sorted() []*frecencyEntry {
+	now := time.Now()
+	entries := make([]*frecencyEntry, 0, len(f.entries))
+	for _, e := range f.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		si, sj := entries[i].score(now), entries[j].score(now)
+		if si != sj {
+			return si > sj
+		}
+		return entries[i].path < entries[j].path
+	})
+	return entries
+}
+
+// fuzzyIndex returns where the runes of keyword, in order but not
+// necessarily next to each other, end in s, or -1 if they don't all occur.
+// Keywords without upper case letters match case insensitively.
+func fuzzyIndex(s, keyword string) int {
+	fold := true
+	for _, r := range keyword {
+		if unicode.IsUpper(r) {
+			fold = false
+			break
+		}
+	}
+
+	end := 0
+	for _, r := range keyword {
+		i := strings.IndexFunc(s[end:], func(c rune) bool {
+			return c == r || fold && unicode.ToLower(c) == r
+		})
+		if i == -1 {
+			return -1
+		}
+		_, n := utf8.DecodeRuneInString(s[end+i:])
+		end += i + n
+	}
+	return end
+}
+
+// matchKeywords reports whether keywords fuzzily match path in order, the
+// last one in its last component, as zoxide does with whole keywords.
+func matchKeywords(path string, keywords []string) bool {
+	if len(keywords) == 0 {
+		return true
+	}
+
+	if fuzzyIndex(filepath.Base(path), keywords[len(keywords)-1]) == -1 {
+		return false
+	}
+
+	off := 0
+	for _, keyword := range keywords {
+		i := fuzzyIndex(path[off:], keyword)
+		if i == -1 {
+			return false
+		}
+		off += i
+	}
+	return true
+}
+
+// Query returns the directories matching keywords, best first. Those that
+// are no longer directories in fsys are dropped, and have to be saved.
+func (f *Frecency) Query(fsys FS, keywords []string, exclude string) []string {
+	var paths []string
+	for _, e := range f.sorted() {
+		if e.path == exclude || !matchKeywords(e.path, keywords) {
+			continue
+		}
+		if fi, err := fsys.Stat(e.path); err != nil || !fi.IsDir() {
+			delete(f.entries, e.path)
+			f.dirty = true
+			continue
+		}
+		paths = append(paths, e.path)
+	}
+	return paths
+}
+
+// importZ reads the data file of z, which is also the format of ours.
+func (f *Frecency) importZ(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "|")
+		if len(fields) != 3 {
+			continue
+		}
+		rank, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		sec, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		f.add(fields[0], rank, time.Unix(sec, 0))
+	}
+	return scanner.Err()
+}
+
+// importZoxide reads db.zo, the data file of zoxide. It is written by
+// bincode, all little endian: the version as a uint32, the number of
+// directories as a uint64, then for each its path as a uint64 length and
+// the bytes, its rank as a float64 and the time of its last visit as
+// uint64 seconds since the epoch.
+func (f *Frecency) importZoxide(r io.Reader) error {
+	br := bufio.NewReader(r)
+	var version uint32
+	if err := binary.Read(br, binary.LittleEndian, &version); err != nil {
+		return fmt.Errorf("reading zoxide database: %w", err)
+	}
+	if version != zoxideVersion {
+		return fmt.Errorf("unsupported zoxide database version %d, expected %d", version, zoxideVersion)
+	}
+
+	var n uint64
+	if err := binary.Read(br, binary.LittleEndian, &n); err != nil {
+		return fmt.Errorf("reading zoxide database: %w", err)
+	}
+	for ; n > 0; n-- {
+		var size uint64
+		if err := binary.Read(br, binary.LittleEndian, &size); err != nil {
+			return fmt.Errorf("reading zoxide database: %w", err)
+		}
+		if size > 1<<16 {
+			return fmt.Errorf("reading zoxide database: path of %d bytes", size)
+		}
+		path := make([]byte, size)
+		if _, err := io.ReadFull(br, path); err != nil {
+			return fmt.Errorf("reading zoxide database: %w", err)
+		}
+		var dir struct {
+			Rank float64
+			Time uint64
+		}
+		if err := binary.Read(br, binary.LittleEndian, &dir); err != nil {
+			return fmt.Errorf("reading zoxide database: %w", err)
+		}
+		f.add(string(path), dir.Rank, time.Unix(int64(dir.Time), 0))
+	}
+	return nil
+}
+
+// Import merges the database of z or zoxide from file, usually ~/.z or
+// ~/.local/share/zoxide/db.zo.
+func (f *Frecency) Import(format, file string) error {
+	r, err := os.Open(replaceTilde(file))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	switch format {
+	case "z":
+		err = f.importZ(r)
+	case "zoxide":
+		err = f.importZoxide(r)
+	default:
+		return fmt.Errorf("unknown format '%s', expected z or zoxide", format)
+	}
+	if err != nil {
+		return err
+	}
+	f.age()
+	return f.Save()
+}
+
+func (f *Frecency) JumpCMD(c *Controller, menu *Menu) CMD {
+	return func(args []string) {
+		paths := f.Query(c.fsys, args, c.cwd)
+		if err := f.Save(); err != nil {
+			c.Warn("%+v", err)
+		}
+		if len(paths) == 0 {
+			c.Warn("No match for '%s'", strings.Join(args, " "))
+			return
+		}
+		if len(args) != 0 {
+			c.Jump(paths[0])
+			return
+		}
+
+		items := make([]MenuItem, 0, len(paths))
+		for _, path := range paths {
+			items = append(items, MenuItem{Text: path, Value: path})
+		}
+		menu.Open("Jump", items, func(item MenuItem) {
+			c.Jump(item.Value)
+		}, nil)
+	}
+}
+
+func (f *Frecency) ImportCMD(c *Controller) CMD {
+	return func(args []string) {
+		if len(args) != 2 {
+			c.Warn("Usage: frecency_import z|zoxide <file>")
+			return
+		}
+		if err := f.Import(args[0], args[1]); err != nil {
+			c.Warn("%+v", err)
+		}
+	}
+}
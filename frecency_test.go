@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestFrecency(t *testing.T) *Frecency {
+	t.Helper()
+	f, err := LoadFrecency(filepath.Join(t.TempDir(), "frecency"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestFuzzyIndex(t *testing.T) {
+	tests := []struct {
+		s       string
+		keyword string
+		want    int
+	}{
+		{"foo", "", 0},
+		{"foo", "f", 1},
+		{"foo", "fo", 2},
+		{"foobar", "fbr", 6},
+		{"foobar", "rb", -1},
+		{"FooBar", "fb", 4},
+		{"foobar", "fB", -1},
+		{"FooBar", "FB", 4},
+		{"héllo", "hl", 4},
+		{"Ärger", "är", 3},
+	}
+	for _, test := range tests {
+		if got := fuzzyIndex(test.s, test.keyword); got != test.want {
+			t.Errorf("fuzzyIndex(%q, %q) = %d, want %d", test.s, test.keyword, got, test.want)
+		}
+	}
+}
+
+func TestMatchKeywords(t *testing.T) {
+	tests := []struct {
+		path     string
+		keywords []string
+		want     bool
+	}{
+		{"/home/u/src", nil, true},
+		{"/home/u/src", []string{"src"}, true},
+		{"/home/u/src", []string{"sc"}, true},
+		{"/home/u/src", []string{"home", "src"}, true},
+		// The last keyword has to match the last component
+		{"/home/u/src", []string{"home"}, false},
+		// Keywords match in order
+		{"/home/u/src", []string{"src", "home"}, false},
+		{"/home/u/src", []string{"u", "s"}, true},
+		{"/home/u/Src", []string{"S"}, true},
+		{"/home/u/src", []string{"S"}, false},
+	}
+	for _, test := range tests {
+		if got := matchKeywords(test.path, test.keywords); got != test.want {
+			t.Errorf("matchKeywords(%q, %q) = %v, want %v", test.path, test.keywords, got, test.want)
+		}
+	}
+}
+
+func TestFrecencyImportZ(t *testing.T) {
+	f := newTestFrecency(t)
+	input := strings.Join([]string{
+		"/a|2|100",
+		"/b|1.5|200",
+		"not an entry",
+		"/c|x|300",
+		"/d|1|x",
+		"/a|3|50",
+	}, "\n")
+	if err := f.importZ(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]frecencyEntry{
+		"/a": {path: "/a", rank: 5, time: time.Unix(100, 0)},
+		"/b": {path: "/b", rank: 1.5, time: time.Unix(200, 0)},
+	}
+	if len(f.entries) != len(want) {
+		t.Errorf("imported %d entries, want %d", len(f.entries), len(want))
+	}
+	for path, w := range want {
+		if e, ok := f.entries[path]; !ok || e.rank != w.rank || !e.time.Equal(w.time) {
+			t.Errorf("entry %s = %+v, want %+v", path, e, w)
+		}
+	}
+}
+
+// zoxideDB writes the entries in the format of db.zo, see importZoxide.
+func zoxideDB(version uint32, entries []frecencyEntry) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, version)
+	binary.Write(&buf, binary.LittleEndian, uint64(len(entries)))
+	for _, e := range entries {
+		binary.Write(&buf, binary.LittleEndian, uint64(len(e.path)))
+		buf.WriteString(e.path)
+		binary.Write(&buf, binary.LittleEndian, e.rank)
+		binary.Write(&buf, binary.LittleEndian, uint64(e.time.Unix()))
+	}
+	return buf.Bytes()
+}
+
+func TestFrecencyImportZoxide(t *testing.T) {
+	entries := []frecencyEntry{
+		{path: "/a", rank: 2.5, time: time.Unix(100, 0)},
+		{path: "/b", rank: 1, time: time.Unix(200, 0)},
+	}
+	db := zoxideDB(zoxideVersion, entries)
+
+	tests := []struct {
+		name  string
+		input []byte
+		ok    bool
+	}{
+		{"whole", db, true},
+		{"empty", nil, false},
+		{"other version", zoxideDB(zoxideVersion+1, entries), false},
+		{"truncated", db[:len(db)-4], false},
+	}
+	for _, test := range tests {
+		f := newTestFrecency(t)
+		err := f.importZoxide(bytes.NewReader(test.input))
+		if (err == nil) != test.ok {
+			t.Errorf("%s: error %v", test.name, err)
+			continue
+		}
+		if !test.ok {
+			continue
+		}
+		for _, w := range entries {
+			if e, ok := f.entries[w.path]; !ok || e.rank != w.rank || !e.time.Equal(w.time) {
+				t.Errorf("%s: entry %s = %+v, want %+v", test.name, w.path, e, w)
+			}
+		}
+	}
+}
+
+func TestFrecencyQuery(t *testing.T) {
+	fsys := newTestFS(t)
+	f := newTestFrecency(t)
+	now := time.Now()
+	f.add("/home/u/docs", 10, now)
+	f.add("/home/u/src", 1, now)
+	f.add("/home/u/gone", 100, now)
+	f.dirty = false
+
+	if got, want := f.Query(fsys, nil, ""), []string{"/home/u/docs", "/home/u/src"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Query() = %v, want %v", got, want)
+	}
+	if !f.dirty {
+		t.Error("dropping a missing directory is not to be saved")
+	}
+	if got, want := f.Query(fsys, []string{"s"}, "/home/u/docs"), []string{"/home/u/src"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Query(s) = %v, want %v", got, want)
+	}
+}
+
+func TestFrecencySave(t *testing.T) {
+	f := newTestFrecency(t)
+	read := func() string {
+		b, _ := os.ReadFile(f.path)
+		return string(b)
+	}
+
+	if err := f.Visit("/a"); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); !strings.HasPrefix(got, "/a|1|") {
+		t.Fatalf("first visit saved %q", got)
+	}
+
+	// Saved again only after a while, or by Save
+	if err := f.Visit("/b"); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); strings.Contains(got, "/b") {
+		t.Errorf("second visit saved at once %q", got)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); !strings.Contains(got, "/b|1|") {
+		t.Errorf("Save saved %q", got)
+	}
+
+	g, err := LoadFrecency(f.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.entries) != 2 || g.dirty {
+		t.Errorf("loaded %d entries, dirty %v", len(g.entries), g.dirty)
+	}
+}
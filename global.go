@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"os/user"
+	"path/filepath"
 )
 
 var (
@@ -34,3 +35,34 @@ func init() {
 
 	StyleM = ParseStyles()
 }
+
+// dataPath returns where the data file name is kept across sessions.
+func dataPath(name string) string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		dir = filepath.Join(UserHomeDir, ".local", "share")
+	}
+	return filepath.Join(dir, "pf", name)
+}
+
+// writeFileAtomic writes data to the file name, creating its directory. The
+// file is replaced at once, so that a crash cannot leave half of it.
+func writeFileAtomic(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// configPath returns where the config file name is read from.
+func configPath(name string) string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(UserHomeDir, ".config")
+	}
+	return filepath.Join(dir, "pf", name)
+}
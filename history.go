@@ -0,0 +1,44 @@
+package main
+
+// History is the list of directories visited in this session, which can be
+// walked back and forth like the history of a browser.
+type History struct {
+	entries []string
+	pos     int
+}
+
+// Visit makes path the current entry, dropping the entries after the
+// current one.
+func (h *History) Visit(path string) {
+	if len(h.entries) != 0 {
+		if h.entries[h.pos] == path {
+			return
+		}
+		h.entries = h.entries[:h.pos+1]
+	}
+	h.entries = append(h.entries, path)
+	h.pos = len(h.entries) - 1
+}
+
+func (h *History) Current() string {
+	if len(h.entries) == 0 {
+		return ""
+	}
+	return h.entries[h.pos]
+}
+
+func (h *History) Back() (string, bool) {
+	if h.pos == 0 {
+		return "", false
+	}
+	h.pos--
+	return h.entries[h.pos], true
+}
+
+func (h *History) Forward() (string, bool) {
+	if h.pos+1 >= len(h.entries) {
+		return "", false
+	}
+	h.pos++
+	return h.entries[h.pos], true
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestHistory(t *testing.T) {
+	// Each step is a visit, or a walk back or forward if path is "<" or
+	// ">", which lands on want, or fails if want is empty
+	steps := []struct {
+		path string
+		want string
+	}{
+		{"<", ""},
+		{"/a", "/a"},
+		{"/b", "/b"},
+		{"/c", "/c"},
+		{"/c", "/c"},
+		{">", ""},
+		{"<", "/b"},
+		{"<", "/a"},
+		{"<", ""},
+		{">", "/b"},
+		// Visiting drops what is forward
+		{"/d", "/d"},
+		{">", ""},
+		{"<", "/b"},
+		// Visiting the current one again does not
+		{"/b", "/b"},
+		{">", "/d"},
+	}
+
+	var h History
+	for i, step := range steps {
+		var got string
+		switch step.path {
+		case "<":
+			got, _ = h.Back()
+		case ">":
+			got, _ = h.Forward()
+		default:
+			h.Visit(step.path)
+			got = h.Current()
+		}
+		if got != step.want {
+			t.Fatalf("step %d %s: got %q, want %q", i, step.path, got, step.want)
+		}
+	}
+}
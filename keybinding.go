@@ -1,180 +1,324 @@
 package main
 
 import (
-	"log"
+	"fmt"
 	"strings"
 )
 
-const (
-	escapedColon = 0
-	escapedComma = 1
-	escapedPlus  = 2
-)
-
-func parseKeyChord(key string) Event {
+func parseKeyChord(key string) (Event, error) {
 	if len(key) == 0 {
-		log.Fatal("empty key")
+		return Invalid.AsEvent(), fmt.Errorf("empty key")
 	}
 
 	lkey := strings.ToLower(key)
 	switch lkey {
 	case "up":
-		return Up.AsEvent()
+		return Up.AsEvent(), nil
 	case "down":
-		return Down.AsEvent()
+		return Down.AsEvent(), nil
 	case "left":
-		return Left.AsEvent()
+		return Left.AsEvent(), nil
 	case "right":
-		return Right.AsEvent()
+		return Right.AsEvent(), nil
 	case "enter", "return":
-		return CtrlM.AsEvent()
+		return CtrlM.AsEvent(), nil
 	case "space":
-		return Key(' ')
+		return Key(' '), nil
 	case "bspace", "bs":
-		return BSpace.AsEvent()
+		return BSpace.AsEvent(), nil
 	case "ctrl-space":
-		return CtrlSpace.AsEvent()
+		return CtrlSpace.AsEvent(), nil
 	case "ctrl-^", "ctrl-6":
-		return CtrlCaret.AsEvent()
+		return CtrlCaret.AsEvent(), nil
 	case "ctrl-/", "ctrl-_":
-		return CtrlSlash.AsEvent()
+		return CtrlSlash.AsEvent(), nil
 	case "ctrl-\\":
-		return CtrlBackSlash.AsEvent()
+		return CtrlBackSlash.AsEvent(), nil
 	case "ctrl-]":
-		return CtrlRightBracket.AsEvent()
+		return CtrlRightBracket.AsEvent(), nil
 	case "change":
-		return Change.AsEvent()
+		return Change.AsEvent(), nil
 	case "backward-eof":
-		return BackwardEOF.AsEvent()
+		return BackwardEOF.AsEvent(), nil
 	case "start":
-		return Start.AsEvent()
+		return Start.AsEvent(), nil
 	case "alt-enter", "alt-return":
-		return CtrlAltKey('m')
+		return CtrlAltKey('m'), nil
 	case "alt-space":
-		return AltKey(' ')
+		return AltKey(' '), nil
 	case "alt-bs", "alt-bspace":
-		return AltBS.AsEvent()
+		return AltBS.AsEvent(), nil
 	case "alt-up":
-		return AltUp.AsEvent()
+		return AltUp.AsEvent(), nil
 	case "alt-down":
-		return AltDown.AsEvent()
+		return AltDown.AsEvent(), nil
 	case "alt-left":
-		return AltLeft.AsEvent()
+		return AltLeft.AsEvent(), nil
 	case "alt-right":
-		return AltRight.AsEvent()
+		return AltRight.AsEvent(), nil
 	case "tab":
-		return Tab.AsEvent()
+		return Tab.AsEvent(), nil
 	case "btab", "shift-tab":
-		return BTab.AsEvent()
+		return BTab.AsEvent(), nil
 	case "esc":
-		return ESC.AsEvent()
+		return ESC.AsEvent(), nil
 	case "del":
-		return Del.AsEvent()
+		return Del.AsEvent(), nil
 	case "home":
-		return Home.AsEvent()
+		return Home.AsEvent(), nil
 	case "end":
-		return End.AsEvent()
+		return End.AsEvent(), nil
 	case "insert":
-		return Insert.AsEvent()
+		return Insert.AsEvent(), nil
 	case "pgup", "page-up":
-		return PgUp.AsEvent()
+		return PgUp.AsEvent(), nil
 	case "pgdn", "page-down":
-		return PgDn.AsEvent()
+		return PgDn.AsEvent(), nil
+	case "ctrl-left":
+		return CtrlLeft.AsEvent(), nil
+	case "ctrl-right":
+		return CtrlRight.AsEvent(), nil
 	case "alt-shift-up", "shift-alt-up":
-		return AltSUp.AsEvent()
+		return AltSUp.AsEvent(), nil
 	case "alt-shift-down", "shift-alt-down":
-		return AltSDown.AsEvent()
+		return AltSDown.AsEvent(), nil
 	case "alt-shift-left", "shift-alt-left":
-		return AltSLeft.AsEvent()
+		return AltSLeft.AsEvent(), nil
 	case "alt-shift-right", "shift-alt-right":
-		return AltSRight.AsEvent()
+		return AltSRight.AsEvent(), nil
 	case "shift-up":
-		return SUp.AsEvent()
+		return SUp.AsEvent(), nil
 	case "shift-down":
-		return SDown.AsEvent()
+		return SDown.AsEvent(), nil
 	case "shift-left":
-		return SLeft.AsEvent()
+		return SLeft.AsEvent(), nil
 	case "shift-right":
-		return SRight.AsEvent()
+		return SRight.AsEvent(), nil
 	case "left-click":
-		return LeftClick.AsEvent()
+		return LeftClick.AsEvent(), nil
 	case "right-click":
-		return RightClick.AsEvent()
+		return RightClick.AsEvent(), nil
 	case "double-click":
-		return DoubleClick.AsEvent()
+		return DoubleClick.AsEvent(), nil
+	case "shift-click":
+		return ShiftClick.AsEvent(), nil
+	case "paste":
+		return Paste.AsEvent(), nil
 	case "f10":
-		return F10.AsEvent()
+		return F10.AsEvent(), nil
 	case "f11":
-		return F11.AsEvent()
+		return F11.AsEvent(), nil
 	case "f12":
-		return F12.AsEvent()
+		return F12.AsEvent(), nil
 	default:
 		runes := []rune(key)
 		if len(key) == 10 && strings.HasPrefix(lkey, "ctrl-alt-") && isAlphabet(lkey[9]) {
-			return CtrlAltKey(rune(key[9]))
+			return CtrlAltKey(rune(key[9])), nil
 		}
 		if len(key) == 6 && strings.HasPrefix(lkey, "ctrl-") && isAlphabet(lkey[5]) {
-			return EventType(CtrlA.Int() + int(lkey[5]) - 'a').AsEvent()
+			return EventType(CtrlA.Int() + int(lkey[5]) - 'a').AsEvent(), nil
 		}
 		if len(runes) == 5 && strings.HasPrefix(lkey, "alt-") {
-			r := runes[4]
-			switch r {
-			case escapedColon:
-				r = ':'
-			case escapedComma:
-				r = ','
-			case escapedPlus:
-				r = '+'
-			}
-			return AltKey(r)
+			return AltKey(runes[4]), nil
 		}
 		if len(key) == 2 && strings.HasPrefix(lkey, "f") && key[1] >= '1' && key[1] <= '9' {
-			return EventType(F1.Int() + int(key[1]) - '1').AsEvent()
+			return EventType(F1.Int() + int(key[1]) - '1').AsEvent(), nil
 		}
 		if len(runes) == 1 {
-			return Key(runes[0])
+			return Key(runes[0]), nil
+		}
+	}
+	return Invalid.AsEvent(), fmt.Errorf("unsupported key '%s'", key)
+}
+
+// parseKeys parses a key, or a sequence of them separated by spaces, as in
+// "g g". A name that is not a key is an error, "gg" is not taken as a
+// sequence.
+func parseKeys(name string) ([]Event, error) {
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		key, err := parseKeyChord(name)
+		if err != nil {
+			return nil, err
+		}
+		return []Event{key}, nil
+	}
+
+	keys := make([]Event, 0, len(fields))
+	for _, field := range fields {
+		key, err := parseKeyChord(field)
+		if err != nil {
+			return nil, err
 		}
+		keys = append(keys, key)
 	}
-	log.Fatalf("unsupported key: " + key)
+	return keys, nil
+}
 
-	// Unreachable
-	return Invalid.AsEvent()
+// A sequence of keys is bound in the keymap under seqEvent, and each of
+// its prefixes is marked under prefixEvent, so that the keymap is also a
+// trie of the sequences. A single key is bound as it is.
+func encodeKeys(keys []Event) string {
+	var sb strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&sb, "%d:%d;", key.Type, key.Char)
+	}
+	return sb.String()
 }
 
-func ParseKeymap(keymap map[Event][]Action, cmds map[string]CMD, str string) {
-	masked := strings.Replace(str, "::", string([]rune{escapedColon, ':'}), -1)
-	masked = strings.Replace(masked, ",:", string([]rune{escapedComma, ':'}), -1)
-	masked = strings.Replace(masked, "+:", string([]rune{escapedPlus, ':'}), -1)
-
-	for _, pairStr := range strings.Split(masked, ",") {
-		pair := strings.SplitN(pairStr, ":", 2)
-
-		var key Event
-		if len(pair[0]) == 1 && pair[0][0] == escapedColon {
-			key = Key(':')
-		} else if len(pair[0]) == 1 && pair[0][0] == escapedComma {
-			key = Key(',')
-		} else if len(pair[0]) == 1 && pair[0][0] == escapedPlus {
-			key = Key('+')
-		} else {
-			key = parseKeyChord(pair[0])
-		}
-
-		specs := strings.Split(pair[1], "+")
-		actions := make([]Action, 0, len(specs))
-		for _, spec := range specs {
-			tokens := strings.Split(spec, " ")
-			cmd, ok := cmds[tokens[0]]
-			if !ok {
-				log.Fatalf("unknown cmd %s", tokens[0])
+func seqEvent(keys []Event) Event {
+	if len(keys) == 1 {
+		return keys[0].Comparable()
+	}
+	return Event{Type: KeySeq, Text: encodeKeys(keys)}
+}
+
+func prefixEvent(keys []Event) Event {
+	return Event{Type: KeyPrefix, Text: encodeKeys(keys)}
+}
+
+func bindKeys(keymap map[Event][]Action, keys []Event, actions []Action) {
+	keymap[seqEvent(keys)] = actions
+	for i := 1; i < len(keys); i++ {
+		keymap[prefixEvent(keys[:i])] = nil
+	}
+}
+
+// unbindKeys removes the binding of keys, and the marks of its prefixes
+// no other sequence starts with. It reports whether keys were bound.
+func unbindKeys(keymap map[Event][]Action, keys []Event) bool {
+	if _, ok := keymap[seqEvent(keys)]; !ok {
+		return false
+	}
+	delete(keymap, seqEvent(keys))
+
+	for i := 1; i < len(keys); i++ {
+		prefix := encodeKeys(keys[:i])
+		used := false
+		for ev := range keymap {
+			// A sequence ending there does not make it a prefix
+			if ev.Type == KeySeq && ev.Text != prefix && strings.HasPrefix(ev.Text, prefix) {
+				used = true
+				break
 			}
+		}
+		if !used {
+			delete(keymap, prefixEvent(keys[:i]))
+		}
+	}
+	return true
+}
+
+// keyName returns how key is written in bindings.
+func keyName(key Event) string {
+	switch key.Type {
+	case Rune:
+		if key.Char == ' ' {
+			return "space"
+		}
+		return string(key.Char)
+	case Alt:
+		return "alt-" + string(key.Char)
+	case CtrlAlt:
+		return "ctrl-alt-" + string(key.Char)
+	}
+	if name, ok := keyNames[key.Type]; ok {
+		return name
+	}
+	if key.Type >= CtrlA && key.Type <= CtrlZ {
+		return "ctrl-" + string(rune('a'+key.Type-CtrlA))
+	}
+	if key.Type >= F1 && key.Type <= F12 {
+		return fmt.Sprintf("f%d", key.Type-F1+1)
+	}
+	return fmt.Sprintf("<%d>", key.Type)
+}
+
+var keyNames = map[EventType]string{
+	Tab:       "tab",
+	CtrlM:     "enter",
+	ESC:       "esc",
+	BSpace:    "bs",
+	BTab:      "shift-tab",
+	Del:       "del",
+	Home:      "home",
+	End:       "end",
+	Insert:    "insert",
+	PgUp:      "pgup",
+	PgDn:      "pgdn",
+	Up:        "up",
+	Down:      "down",
+	Left:      "left",
+	Right:     "right",
+	SUp:       "shift-up",
+	SDown:     "shift-down",
+	SLeft:     "shift-left",
+	SRight:    "shift-right",
+	CtrlLeft:  "ctrl-left",
+	CtrlRight: "ctrl-right",
+	CtrlSpace: "ctrl-space",
+	AltBS:     "alt-bs",
+	AltUp:     "alt-up",
+	AltDown:   "alt-down",
+	AltLeft:   "alt-left",
+	AltRight:  "alt-right",
+	Paste:     "paste",
+}
 
-			action := ToAction(cmd, tokens[1:])
-			actions = append(actions, action)
+func keysName(keys []Event) string {
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, keyName(key))
+	}
+	return strings.Join(names, " ")
+}
+
+// eachBinding parses bindings of keys to commands, as in
+// "j:next,tab:toggle_mark; next", passing each one to fn along with the key
+// and the commands as written. A key is separated from its commands by the
+// first ':' after it, so ':' and ',' can be bound too, and bindings by an
+// unquoted ','. The key may be a sequence, see parseKeys. Commands are
+// parsed by ParseScript.
+func eachBinding(str string, fn func(keys []Event, name string, script Script, text string) error) error {
+	rs := []rune(str)
+	p := &scriptParser{
+		input: str,
+		rs:    rs,
+		stop:  func(r rune) bool { return r == ',' },
+	}
+
+	for p.pos < len(rs) {
+		start := p.pos
+		i := p.pos + 1
+		for i < len(rs) && rs[i] != ':' {
+			i++
+		}
+		if i >= len(rs) {
+			return p.errorf(start, "missing ':' after key")
+		}
+		// "alt-::command" binds alt-:
+		if i+1 < len(rs) && rs[i+1] == ':' {
+			i++
+		}
+		name := string(rs[start:i])
+		keys, err := parseKeys(name)
+		if err != nil {
+			return p.errorf(start, "%v", err)
+		}
+
+		p.pos = i + 1
+		script, err := p.script()
+		if err != nil {
+			return err
+		}
+		if err := fn(keys, name, script, strings.TrimSpace(string(rs[i+1:p.pos]))); err != nil {
+			return p.errorf(i+1, "%v", err)
 		}
 
-		keymap[key] = actions
+		// ','
+		p.pos++
 	}
+	return nil
 }
 
 func isAlphabet(char uint8) bool {
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		want []Event
+		ok   bool
+	}{
+		{"j", []Event{Key('j')}, true},
+		{"J", []Event{Key('J')}, true},
+		{"space", []Event{Key(' ')}, true},
+		{" ", []Event{Key(' ')}, true},
+		{"enter", []Event{CtrlM.AsEvent()}, true},
+		{"Enter", []Event{CtrlM.AsEvent()}, true},
+		{"ctrl-a", []Event{CtrlA.AsEvent()}, true},
+		{"alt-é", []Event{AltKey('é')}, true},
+		{"ctrl-alt-x", []Event{CtrlAltKey('x')}, true},
+		{"f5", []Event{F5.AsEvent()}, true},
+		{"é", []Event{Key('é')}, true},
+		{"g g", []Event{Key('g'), Key('g')}, true},
+		{"  g   ctrl-a  ", []Event{Key('g'), CtrlA.AsEvent()}, true},
+		{"g enter", []Event{Key('g'), CtrlM.AsEvent()}, true},
+		{"", nil, false},
+		{"gg", nil, false},
+		{"enterr", nil, false},
+		{"ctrl-1", nil, false},
+		{"g enterr", nil, false},
+	}
+	for _, test := range tests {
+		got, err := parseKeys(test.name)
+		if (err == nil) != test.ok || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseKeys(%q) = %v, %v, want %v", test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestKeysName(t *testing.T) {
+	for _, name := range []string{"j", "space", "enter", "ctrl-a", "alt-x", "f12", "g g", "g shift-tab"} {
+		keys, err := parseKeys(name)
+		if err != nil {
+			t.Errorf("parseKeys(%q): %v", name, err)
+			continue
+		}
+		if got := keysName(keys); got != name {
+			t.Errorf("keysName(parseKeys(%q)) = %q", name, got)
+		}
+	}
+}
+
+func TestEncodeKeys(t *testing.T) {
+	seqs := [][]Event{
+		{Key('g')},
+		{Key('g'), Key('g')},
+		{Key('g'), Key('h')},
+		{AltKey('g'), Key('g')},
+		{Key('g'), Key('g'), Key('g')},
+		{Key('1'), Key('2')},
+		{Key('1'), Key('2'), Key('3')},
+	}
+	seen := make(map[string][]Event)
+	for _, keys := range seqs {
+		s := encodeKeys(keys)
+		if prev, ok := seen[s]; ok {
+			t.Errorf("%v and %v are both encoded as %q", prev, keys, s)
+		}
+		seen[s] = keys
+	}
+
+	// A single key is bound as it is, without what only events carry
+	ev := Key('g')
+	ev.Count = 3
+	if got := seqEvent([]Event{ev}); got != Key('g') {
+		t.Errorf("seqEvent(g) = %v", got)
+	}
+}
+
+func TestBindKeys(t *testing.T) {
+	keymap := make(map[Event][]Action)
+	gg := []Event{Key('g'), Key('g')}
+	ggx := []Event{Key('g'), Key('g'), Key('x')}
+	bindKeys(keymap, gg, nil)
+	bindKeys(keymap, ggx, nil)
+	for _, prefix := range [][]Event{{Key('g')}, gg} {
+		if _, ok := keymap[prefixEvent(prefix)]; !ok {
+			t.Errorf("%s is not a prefix", keysName(prefix))
+		}
+	}
+
+	if !unbindKeys(keymap, ggx) {
+		t.Fatal("g g x was not bound")
+	}
+	if _, ok := keymap[prefixEvent(gg)]; ok {
+		t.Error("g g is still a prefix")
+	}
+	// Still the prefix of g g
+	if _, ok := keymap[prefixEvent([]Event{Key('g')})]; !ok {
+		t.Error("g is no longer a prefix")
+	}
+	if unbindKeys(keymap, ggx) {
+		t.Error("unbound g g x twice")
+	}
+}
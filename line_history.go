@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+const (
+	// Lines kept by a LineHistory, the oldest are dropped first
+	lineHistoryMaxEntries = 1000
+)
+
+// LineHistory is the list of lines entered in the CLI, oldest first. It is
+// saved to a file, one line per line, whenever it changes.
+type LineHistory struct {
+	path    string
+	entries []string
+}
+
+func LoadLineHistory(path string) (*LineHistory, error) {
+	h := &LineHistory{path: path}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return h, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	h.trim()
+	return h, scanner.Err()
+}
+
+func (h *LineHistory) save() error {
+	var sb strings.Builder
+	for _, line := range h.entries {
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+
+	return writeFileAtomic(h.path, []byte(sb.String()))
+}
+
+func (h *LineHistory) trim() {
+	if n := len(h.entries) - lineHistoryMaxEntries; n > 0 {
+		h.entries = h.entries[n:]
+	}
+}
+
+// Add makes line the newest entry, removing an older copy of it.
+func (h *LineHistory) Add(line string) error {
+	if line == "" || strings.ContainsAny(line, "\r\n") {
+		return nil
+	}
+
+	entries := h.entries[:0]
+	for _, e := range h.entries {
+		if e != line {
+			entries = append(entries, e)
+		}
+	}
+	h.entries = append(entries, line)
+	h.trim()
+	return h.save()
+}
+
+// Search returns the index of the entry starting with prefix nearest to
+// from in the direction of step, -1 for older and 1 for newer ones. It is
+// -1 if there is none.
+func (h *LineHistory) Search(prefix string, from, step int) int {
+	for i := from + step; i >= 0 && i < len(h.entries); i += step {
+		if strings.HasPrefix(h.entries[i], prefix) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (h *LineHistory) Get(i int) string {
+	return h.entries[i]
+}
+
+func (h *LineHistory) Len() int {
+	return len(h.entries)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func lineHistoryEntries(h *LineHistory) []string {
+	var entries []string
+	for i := 0; i < h.Len(); i++ {
+		entries = append(entries, h.Get(i))
+	}
+	return entries
+}
+
+func TestLineHistoryAdd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	h, err := LoadLineHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"a", []string{"a"}},
+		{"b", []string{"a", "b"}},
+		{"", []string{"a", "b"}},
+		{"x\ny", []string{"a", "b"}},
+		// Moved to the end
+		{"a", []string{"b", "a"}},
+		{"c", []string{"b", "a", "c"}},
+	}
+	for _, test := range tests {
+		if err := h.Add(test.line); err != nil {
+			t.Fatal(err)
+		}
+		if got := lineHistoryEntries(h); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("after adding %q: %q, want %q", test.line, got, test.want)
+		}
+	}
+
+	loaded, err := LoadLineHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := lineHistoryEntries(loaded), lineHistoryEntries(h); !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %q, want %q", got, want)
+	}
+}
+
+func TestLineHistoryTrim(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	var sb strings.Builder
+	for i := 0; i < lineHistoryMaxEntries+10; i++ {
+		sb.WriteString("line\n\n")
+	}
+	sb.WriteString("last\n")
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := LoadLineHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Len() != lineHistoryMaxEntries || h.Get(h.Len()-1) != "last" {
+		t.Errorf("loaded %d entries, the last %q", h.Len(), h.Get(h.Len()-1))
+	}
+}
+
+func TestLineHistorySearch(t *testing.T) {
+	h := &LineHistory{entries: []string{"cd a", "view", "cd b", "cd c"}}
+
+	tests := []struct {
+		prefix string
+		from   int
+		step   int
+		want   int
+	}{
+		{"cd", h.Len(), -1, 3},
+		{"cd", 3, -1, 2},
+		{"cd", 2, -1, 0},
+		{"cd", 0, -1, -1},
+		{"cd", 0, 1, 2},
+		{"cd", 3, 1, -1},
+		{"v", h.Len(), -1, 1},
+		{"", 1, -1, 0},
+		{"x", h.Len(), -1, -1},
+	}
+	for _, test := range tests {
+		if got := h.Search(test.prefix, test.from, test.step); got != test.want {
+			t.Errorf("Search(%q, %d, %d) = %d, want %d", test.prefix, test.from, test.step, got, test.want)
+		}
+	}
+}
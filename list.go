@@ -1,6 +1,8 @@
 package main
 
 import (
+	"strconv"
+
 	"github.com/gdamore/tcell/v2"
 )
 
@@ -31,7 +33,7 @@ type ListRow struct {
 type List struct {
 	rows  []ListRow
 	Style tcell.Style
-	Marks map[string]struct{}
+	Marks *Selection
 }
 
 func (d *List) GetFileInfo(idx int) *FileInfo {
@@ -42,8 +44,18 @@ func (d *List) GetFileInfo(idx int) *FileInfo {
 	return row.FileInfo
 }
 
-func (d *List) Get(idx int, width int, selected bool) ListItem {
-	if idx < 0 || idx >= len(d.rows) || width <= 2 {
+// Get draws the row idx. Highlighted rows, of a visual range, are drawn
+// like the selected one, dimmed. Marked rows are numbered in the gutter by
+// their place in the output order, and directories with marks below them
+// are followed by how many.
+func (d *List) Get(idx int, width int, selected, highlighted bool) ListItem {
+	gutter := 1
+	if d.Marks != nil {
+		if n := len(strconv.Itoa(d.Marks.Len())); n > gutter {
+			gutter = n
+		}
+	}
+	if idx < 0 || idx >= len(d.rows) || width <= gutter+1 {
 		return nil
 	}
 
@@ -54,19 +66,27 @@ func (d *List) Get(idx int, width int, selected bool) ListItem {
 	}
 	if selected {
 		style = style.Reverse(true)
+	} else if highlighted {
+		style = style.Reverse(true).Dim(true)
+		selected = true
 	}
 
 	contents := make([]Content, width)
-	if _, ok := d.Marks[row.FileInfo.Path]; ok {
-		contents[0].R = '>'
-		style := tcell.StyleDefault.Background(tcell.ColorReset).Foreground(tcell.ColorRed)
-		contents[0].Style = &style
+	if row.FileInfo != nil && d.Marks != nil {
+		if n, ok := d.Marks.Number(row.FileInfo.Path); ok {
+			num := strconv.Itoa(n)
+			style := tcell.StyleDefault.Background(tcell.ColorReset).Foreground(tcell.ColorRed)
+			for j, r := range num {
+				contents[gutter-len(num)+j].R = r
+				contents[gutter-len(num)+j].Style = &style
+			}
+		}
 	}
-	contents[1].R = ' '
+	contents[gutter].R = ' '
 	if selected {
-		contents[1].Style = &style
+		contents[gutter].Style = &style
 	}
-	i := 2
+	i := gutter + 1
 	putList := func(x ListItem) {
 		for _, c := range x {
 			if i >= width {
@@ -81,6 +101,14 @@ func (d *List) Get(idx int, width int, selected bool) ListItem {
 		}
 	}
 	putList(row.Left(selected))
+	if row.FileInfo != nil && d.Marks != nil {
+		if n := d.Marks.Below(row.FileInfo.Path); n > 0 {
+			style := d.Style.Foreground(tcell.ColorRed).Reverse(selected)
+			below := ListItem{}
+			below.WriteString(" +"+strconv.Itoa(n), &style)
+			putList(below)
+		}
+	}
 
 	right := row.Right(selected)
 	avail := width - i - 1
@@ -5,6 +5,10 @@ type ListView struct {
 	List        List
 	SelectAt    int
 	ViewBeginAt int
+
+	// The visual range goes from VisualAt to SelectAt
+	Visual   bool
+	VisualAt int
 }
 
 func (v *ListView) Draw() {
@@ -28,12 +32,13 @@ func (v *ListView) Draw() {
 		v.ViewBeginAt = v.SelectAt - v.Win.H()
 	}
 
+	begin, end := v.Range()
 	idx := v.ViewBeginAt
 	for row := 0; row <= v.Win.H(); row++ {
 		if idx >= size {
 			break
 		}
-		item := v.List.Get(idx, v.Win.W(), idx == v.SelectAt)
+		item := v.List.Get(idx, v.Win.W(), idx == v.SelectAt, idx >= begin && idx <= end)
 		if len(item) != 0 {
 			v.Win.Render(0, row, item, v.List.Style, false)
 		}
@@ -68,22 +73,56 @@ func (v *ListView) Select(y int) {
 	v.SelectAt = v.ViewBeginAt + y - v.Win.Y1
 }
 
-func (v *ListView) Mark(unmark, toggle bool) {
-	info := v.List.GetFileInfo(v.SelectAt)
-	if info == nil {
-		return
+// Range returns the first and last rows of the visual range, or the
+// selected row twice if there is none.
+func (v *ListView) Range() (int, int) {
+	if !v.Visual {
+		return v.SelectAt, v.SelectAt
+	}
+	begin, end := v.VisualAt, v.SelectAt
+	if begin > end {
+		begin, end = end, begin
 	}
+	if begin < 0 {
+		begin = 0
+	}
+	if size := v.List.Size(); end >= size {
+		end = size - 1
+	}
+	return begin, end
+}
 
-	path := info.Path
+// Mark marks the visual range, or the selected row. Toggled, the rows are
+// unmarked if all of them are marked, and marked otherwise.
+func (v *ListView) Mark(unmark, toggle bool) {
+	begin, end := v.Range()
+	v.markRows(begin, end, unmark, toggle)
+}
+
+func (v *ListView) markRows(begin, end int, unmark, toggle bool) {
 	if toggle {
-		_, ok := v.List.Marks[path]
-		unmark = ok
+		unmark = true
+		for i := begin; i <= end; i++ {
+			info := v.List.GetFileInfo(i)
+			if info == nil {
+				continue
+			}
+			if !v.List.Marks.Has(info.Path) {
+				unmark = false
+				break
+			}
+		}
 	}
 
-	if unmark {
-		delete(v.List.Marks, path)
-		return
+	for i := begin; i <= end; i++ {
+		info := v.List.GetFileInfo(i)
+		if info == nil {
+			continue
+		}
+		if unmark {
+			v.List.Marks.Remove(info.Path)
+		} else {
+			v.List.Marks.Add(info.Path)
+		}
 	}
-
-	v.List.Marks[path] = struct{}{}
 }
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
 )
@@ -18,7 +20,38 @@ func initLog() {
 	log.SetOutput(f)
 }
 
+// bindModesFlag collects the values of --bind-mode, given several times.
+// They are checked as they are parsed, before the screen is taken over.
+type bindModesFlag []string
+
+func (f *bindModesFlag) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *bindModesFlag) Set(s string) error {
+	if _, _, err := splitModeBindings(s); err != nil {
+		return err
+	}
+	*f = append(*f, s)
+	return nil
+}
+
 func main() {
+	var bindModes bindModesFlag
+	flag.Var(&bindModes, "bind-mode", "bind keys in a mode, as <mode>:<key>:<commands>[,<key>:<commands>...], e.g. filter:ctrl-j:next; can be repeated")
+	previewCMD := flag.String("preview", "", "command to preview the selected entry, {} is replaced by its path")
+	previewTimeout := flag.Duration("preview-timeout", 3*time.Second, "kill the preview command after this long")
+	keyTimeout := flag.Duration("key-timeout", time.Second, "wait this long for the next key of a sequence, if the keys typed are bound too")
+	outputOrder := SelectionOrderMark
+	flag.Func("output-order", "print marks in mark, path or listing order (default mark)", func(s string) (err error) {
+		outputOrder, err = ParseSelectionOrder(s)
+		return err
+	})
+	clearFilter := flag.Bool("clear-filter", false, "clear the filter on enter, keeping the entry selected, instead of keeping the filter")
+	pasteJump := flag.Bool("paste-jump", false, "jump to a path pasted outside of the command line")
+	extract := flag.Bool("extract", false, "extract picked archive members to a temporary directory and print their paths")
+	flag.Parse()
+
 	initLog()
 
 	defStyle := tcell.StyleDefault.Background(tcell.ColorReset).Foreground(tcell.ColorReset)
@@ -35,10 +68,23 @@ func main() {
 	s.EnablePaste()
 	s.Clear()
 
-	marks := make(map[string]struct{})
+	marks := NewSelection()
+	marks.SetOrder(outputOrder)
 	printMarks := func() {
-		for mark := range marks {
-			fmt.Println(mark)
+		paths := marks.Sorted()
+		if *extract {
+			var skipped []string
+			var err error
+			if paths, skipped, err = extractPaths(paths); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			for _, path := range skipped {
+				fmt.Fprintf(os.Stderr, "not extracting %s: link out of the archive\n", path)
+			}
+		}
+		for _, path := range paths {
+			fmt.Println(path)
 		}
 	}
 
@@ -55,14 +101,93 @@ func main() {
 	}
 	defer quit()
 
-	dirs := NewDirSet(StyleM)
-	c := NewController(dirs, marks, s)
-	cli := NewCLI(c)
-	cmds := initBuiltinCMDTable(c, cli)
+	cwd, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+
+	fsys := LocalFS{}
+	dirs := NewDirSet(fsys, StyleM)
+	previewer := NewPreviewer(fsys, *previewCMD, *previewTimeout)
+	c := NewController(fsys, cwd, dirs, previewer, marks, s)
+	if *previewCMD != "" {
+		c.TogglePreview()
+	}
+	bookmarks, err := LoadBookmarks(dataPath("bookmarks"))
+	if err != nil {
+		c.Warn("%+v", err)
+	}
+
+	frecency, err := LoadFrecency(dataPath("frecency"))
+	if err != nil {
+		c.Warn("%+v", err)
+	}
+	c.SetFrecency(frecency)
+	defer func() {
+		if err := frecency.Save(); err != nil {
+			log.Printf("%+v", err)
+		}
+	}()
+
+	modes := NewModes(c)
+	c.SetModes(modes)
+	cli := NewCLI(c, modes)
+	pager := NewPager(c, cli, modes)
+	menu := NewMenu(c, modes)
+	selection := NewSelectionView(c, modes)
+	keys := NewKeyReader(c, modes)
+	cmds := initBuiltinCMDTable(c, cli, pager, menu, selection, keys, bookmarks, frecency)
+	aliases := NewAliases(c, cmds)
+	cmds["alias"] = aliases.AliasCMD
+	cmds["unalias"] = aliases.UnaliasCMD
+	cmds["commands"] = aliases.ListCMD(menu)
+	cmds["map"] = modes.MapCMD
+	cmds["unmap"] = modes.UnmapCMD
+	cmds["bind_mode"] = modes.BindModeCMD
+	cmds["unbind_mode"] = modes.UnbindModeCMD
+	cmds["maps"] = modes.ListCMD(menu)
 	cli.SetCMDs(cmds)
+	cli.SetClearFilter(*clearFilter)
+	if err := cli.AddModes(cmds); err != nil {
+		log.Fatalf("%+v", err)
+	}
+	histories := make(map[byte]*LineHistory)
+	for mode, name := range map[byte]string{':': "cmd_history", '/': "filter_history"} {
+		h, err := LoadLineHistory(dataPath(name))
+		if err != nil {
+			c.Warn("%+v", err)
+		}
+		histories[mode] = h
+	}
+	cli.SetHistories(histories)
 	keybindings := initBuiltinKeybindings()
-	keymap := initBuiltinKeymap(cmds)
-	ParseKeymap(keymap, cmds, keybindings)
+	if *pasteJump {
+		keybindings += ",paste:jump_paste"
+	}
+	normal := NewBindings(c, initBuiltinKeymap(cmds), cmds)
+	if err := normal.Parse(keybindings); err != nil {
+		log.Fatalf("%+v", err)
+	}
+	modes.Add("normal", normal)
+	modes.Enter("normal")
+	visualBindings := NewBindings(c, initBuiltinKeymap(cmds), cmds)
+	if err := visualBindings.Parse(visualKeybindings()); err != nil {
+		log.Fatalf("%+v", err)
+	}
+	modes.Add("visual", visualBindings)
+	cli.SetCompleters(initBuiltinCompleters(c, bookmarks, aliases, modes))
+	for _, err := range LoadConfig(configPath("pfrc"), cmds, c, modes.Keymap()) {
+		c.Warn("%v", err)
+	}
+	for _, str := range bindModes {
+		if err := modes.Parse(str); err != nil {
+			s.Fini()
+			fmt.Fprintf(os.Stderr, "invalid value %q for flag -bind-mode: %v\n", str, err)
+			os.Exit(2)
+		}
+	}
+
+	dispatcher := NewDispatcher(c, modes.Keymap(), *keyTimeout)
 
 	eventCh := make(chan Event, 1)
 	go func() {
@@ -76,9 +201,13 @@ func main() {
 
 		select {
 		case ev := <-eventCh:
-			HandleAction(ev, &keymap)
+			dispatcher.Dispatch(ev)
+		case <-dispatcher.Timeout():
+			dispatcher.Flush()
 		case dirEvent := <-dirs.Event():
 			c.HandleDirEvent(dirEvent)
+		case previewEvent := <-previewer.Event():
+			c.HandlePreviewEvent(previewEvent)
 		}
 	}
 }
@@ -98,23 +227,52 @@ func initBuiltinKeymap(cmds map[string]CMD) map[Event][]Action {
 	return keymap
 }
 
-func initBuiltinCMDTable(c *Controller, cli *CLI) map[string]CMD {
-	scroll := func(up bool) func(args []string) {
-		return func(args []string) {
-			i := ParseArgOrDefault(args, 0, 1)
+func initBuiltinCMDTable(c *Controller, cli *CLI, pager *Pager, menu *Menu, selection *SelectionView, keys *KeyReader, bookmarks *Bookmarks, frecency *Frecency) map[string]CMD {
+	scroll := func(up bool) func(int, []string) {
+		return func(count int, args []string) {
+			i := ParseArgOrDefault(args, 0, 1).(int) * countOr(count, 1)
 			left := ParseArgOrDefault(args, 1, false)
-			c.ScrollDown(i.(int), left.(bool))
+			if up {
+				i = -i
+			}
+			c.ScrollDown(i, left.(bool))
+		}
+	}
+
+	previewScroll := func(up bool) func(int, []string) {
+		return func(count int, args []string) {
+			i := ParseArgOrDefault(args, 0, 1).(int) * countOr(count, 1)
+			if up {
+				i = -i
+			}
+			c.PreviewScrollDown(i)
+		}
+	}
+
+	levels := func(fn func(int)) func(int, []string) {
+		return func(count int, _ []string) {
+			fn(countOr(count, 1))
+		}
+	}
+
+	visual := func(on bool) func() {
+		return func() {
+			c.Visual(on)
 		}
 	}
 
 	mouse := func(action func(x, y int)) func(Event, *map[Event][]Action, []string) {
 		return func(ev Event, _ *map[Event][]Action, _ []string) {
+			// Not run by the mouse, e.g. typed after ':'
 			me := ev.MouseEvent
+			if me == nil {
+				return
+			}
 			action(me.X, me.Y)
 		}
 	}
 
-	return map[string]CMD{
+	cmds := map[string]CMD{
 		"resize":          c.Resize,
 		"next":            c.Next,
 		"prev":            c.Prev,
@@ -130,16 +288,104 @@ func initBuiltinCMDTable(c *Controller, cli *CLI) map[string]CMD {
 		"mark":            c.Mark,
 		"unmark":          c.Unmark,
 		"toggle_mark":     c.ToggleMark,
+		"next_mark":       c.NextMark,
+		"prev_mark":       c.PrevMark,
+		"selection":       selection.Open,
+		"mark_all":        c.MarkAll,
+		"invert_marks":    c.InvertMarks,
+		"mark_glob":       c.MarkGlob,
+		"unmark_glob":     c.UnmarkGlob,
+		"mark_regex":      c.MarkRegex,
+		"mark_newer":      c.MarkNewer,
+		"output_order":    c.OutputOrder,
 		"select":          mouse(c.Select),
 		"goto":            mouse(c.Goto),
-		"in":              c.In,
-		"out":             c.Out,
+		"mark_range":      mouse(c.MarkRange),
+		"visual":          visual(true),
+		"visual_end":      visual(false),
+		"in":              levels(c.InLevels),
+		"out":             levels(c.OutLevels),
 		"quit":            c.Quit,
 		"mouse":           c.HandleMouseEvent,
 		"toggle_dir_info": c.ToggleDirInfo,
+		"toggle_preview":  c.TogglePreview,
+		"preview_down":    previewScroll(false),
+		"preview_up":      previewScroll(true),
 		"dir":             c.DirDo,
 		"command":         cli.StartCMD,
 		"filter":          cli.StartFilter,
+		"view":            pager.Open,
+		"set_bookmark":    bookmarks.SetCMD(c, keys),
+		"jump_bookmark":   bookmarks.JumpCMD(c, keys),
+		"bookmarks":       bookmarks.ListCMD(c, menu),
+		"back":            c.Back,
+		"forward":         c.Forward,
+		"history":         c.ShowHistory(menu),
+		"jump":            frecency.JumpCMD(c, menu),
+		"frecency_import": frecency.ImportCMD(c),
+		"cd":              c.Cd,
+		"jump_paste":      c.JumpPaste,
+	}
+	for name, cmd := range cli.CMDs() {
+		cmds[name] = cmd
+	}
+	return cmds
+}
+
+func initBuiltinCompleters(c *Controller, bookmarks *Bookmarks, aliases *Aliases, modes *Modes) map[string]Completer {
+	path := func(dirOnly bool) Completer {
+		return func(args []string) []string {
+			return completePath(c.fsys, c.cwd, args[len(args)-1], dirOnly)
+		}
+	}
+
+	bookmarkKeys := func([]string) []string {
+		var keys []string
+		for _, r := range bookmarks.Keys() {
+			keys = append(keys, string(r))
+		}
+		return keys
+	}
+
+	modeKeys := func(mode string) []string {
+		bindings, err := modes.Get(mode)
+		if err != nil {
+			return nil
+		}
+		return bindings.Keys()
+	}
+
+	return map[string]Completer{
+		"dir":             completeWords(dirCMDs...),
+		"toggle_dir_info": completeWords(dirInfoColumns...),
+		"output_order":    completeWords(selectionOrders...),
+		"cd":              path(true),
+		"set_bookmark":    bookmarkKeys,
+		"jump_bookmark":   bookmarkKeys,
+		"unalias": func([]string) []string {
+			return aliases.Names()
+		},
+		"unmap": func([]string) []string {
+			return modeKeys("normal")
+		},
+		"bind_mode": func(args []string) []string {
+			if len(args) == 1 {
+				return modes.Names()
+			}
+			return nil
+		},
+		"unbind_mode": func(args []string) []string {
+			if len(args) == 1 {
+				return modes.Names()
+			}
+			return modeKeys(args[0])
+		},
+		"frecency_import": func(args []string) []string {
+			if len(args) == 1 {
+				return []string{"z", "zoxide"}
+			}
+			return path(false)(args)
+		},
 	}
 }
 
@@ -148,27 +394,70 @@ func initBuiltinKeybindings() string {
 		"ctrl-l:resize",
 		"j:next",
 		"k:prev",
-		"g:top",
+		"g g:top",
+		"g h:cd ~",
 		"G:bottom",
 		"ctrl-e:scroll_down 1",
 		"ctrl-y:scroll_up 1",
 		"ctrl-d:half_page_down",
 		"ctrl-u:half_page_up",
 		"space:toggle_mark",
-		"tab:toggle_mark+next",
-		"shift-tab:toggle_mark+prev",
-		"enter:clear_marks+mark+quit",
+		"tab:toggle_mark; next",
+		"shift-tab:toggle_mark; prev",
+		"enter:clear_marks; mark; quit",
 		"l:in",
 		"h:out",
 		"ctrl-c:quit",
 		"q:quit",
 		"left-click:select",
+		"shift-click:mark_range",
 		"double-click:goto",
 		"right-click:out",
 		"i:toggle_dir_info perm hsize mtime link_target",
 		"s:dir sort_by_size",
+		"p:toggle_preview",
+		"v:view",
+		"m:set_bookmark",
+		"':jump_bookmark",
+		"B:bookmarks",
+		"H:back",
+		"L:forward",
+		"z:jump",
+		"shift-down:preview_down 1",
+		"shift-up:preview_up 1",
+		"pgdn:preview_down 10",
+		"pgup:preview_up 10",
 		"::command",
 		"/:filter",
+		"V:visual",
+		"]:next_mark",
+		"[:prev_mark",
+		"S:selection",
+	}
+	return strings.Join(binds, ",")
+}
+
+// visualKeybindings move the end of the visual range and mark it.
+func visualKeybindings() string {
+	binds := []string{
+		"ctrl-l:resize",
+		"j:next",
+		"k:prev",
+		"down:next",
+		"up:prev",
+		"g g:top",
+		"G:bottom",
+		"ctrl-e:scroll_down 1",
+		"ctrl-y:scroll_up 1",
+		"ctrl-d:half_page_down",
+		"ctrl-u:half_page_up",
+		"left-click:select",
+		"space:toggle_mark; visual_end",
+		"m:mark; visual_end",
+		"u:unmark; visual_end",
+		"V:visual_end",
+		"esc:visual_end",
+		"ctrl-c:visual_end",
 	}
 	return strings.Join(binds, ",")
 }
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i *memFileInfo) Name() string       { return i.name }
+func (i *memFileInfo) Size() int64        { return i.size }
+func (i *memFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i *memFileInfo) ModTime() time.Time { return i.modTime }
+func (i *memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memFileInfo) Sys() any           { return nil }
+
+type memNode struct {
+	info     memFileInfo
+	data     []byte
+	link     string
+	children map[string]*memNode
+}
+
+type memFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f memFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f memFile) Close() error {
+	return nil
+}
+
+// MemFS is a file system tree kept in memory, for fixtures that should not
+// touch the disk.
+type MemFS struct {
+	root *memNode
+	cwd  string
+}
+
+func NewMemFS() *MemFS {
+	return &MemFS{
+		root: &memNode{
+			info:     memFileInfo{name: "/", mode: fs.ModeDir | 0755, modTime: time.Now()},
+			children: make(map[string]*memNode),
+		},
+		cwd: "/",
+	}
+}
+
+// lookup walks to name, following links in the middle of the path, and at
+// the end of it if follow is set.
+func (m *MemFS) lookup(op, name string, follow bool) (*memNode, error) {
+	return m.walk(op, name, follow, 0)
+}
+
+func (m *MemFS) walk(op, name string, follow bool, depth int) (*memNode, error) {
+	if depth > 40 {
+		return nil, &fs.PathError{Op: op, Path: name, Err: syscall.ELOOP}
+	}
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(m.cwd, name)
+	}
+
+	node := m.root
+	dir := "/"
+	tokens := strings.Split(strings.Trim(filepath.Clean(name), "/"), "/")
+	for i, token := range tokens {
+		if token == "" {
+			continue
+		}
+		if !node.info.IsDir() {
+			return nil, &fs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+		}
+		child, ok := node.children[token]
+		if !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+
+		last := i == len(tokens)-1
+		if child.link != "" && (!last || follow) {
+			target := child.link
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(dir, target)
+			}
+			if !last {
+				target = filepath.Join(append([]string{target}, tokens[i+1:]...)...)
+			}
+			return m.walk(op, target, follow, depth+1)
+		}
+
+		node = child
+		dir = filepath.Join(dir, token)
+	}
+	return node, nil
+}
+
+func (m *MemFS) create(op, name string, n *memNode) error {
+	parent, err := m.lookup(op, filepath.Dir(name), true)
+	if err != nil {
+		return err
+	}
+	if !parent.info.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+	}
+	n.info.name = filepath.Base(name)
+	n.info.modTime = time.Now()
+	parent.children[n.info.name] = n
+	return nil
+}
+
+// MkdirAll creates the directory name along with any missing parents.
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	if n, err := m.lookup("mkdir", name, true); err == nil {
+		if !n.info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+		}
+		return nil
+	}
+	if err := m.MkdirAll(filepath.Dir(name), perm); err != nil {
+		return err
+	}
+	return m.create("mkdir", name, &memNode{
+		info:     memFileInfo{mode: fs.ModeDir | perm.Perm()},
+		children: make(map[string]*memNode),
+	})
+}
+
+// WriteFile creates or replaces the file name, its parent has to exist.
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return m.create("write", name, &memNode{
+		info: memFileInfo{size: int64(len(data)), mode: perm.Perm()},
+		data: data,
+	})
+}
+
+// Symlink creates name as a link to target.
+func (m *MemFS) Symlink(target, name string) error {
+	return m.create("symlink", name, &memNode{
+		info: memFileInfo{size: int64(len(target)), mode: fs.ModeSymlink | 0777},
+		link: target,
+	})
+}
+
+func (m *MemFS) Open(name string) (fs.File, error) {
+	n, err := m.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if n.info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	}
+	info := n.info
+	return memFile{bytes.NewReader(n.data), &info}, nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.FileInfo, error) {
+	n, err := m.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !n.info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: syscall.ENOTDIR}
+	}
+
+	infos := make([]fs.FileInfo, 0, len(n.children))
+	for _, child := range n.children {
+		info := child.info
+		infos = append(infos, &info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	n, err := m.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	info := n.info
+	return &info, nil
+}
+
+func (m *MemFS) Lstat(name string) (fs.FileInfo, error) {
+	n, err := m.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	info := n.info
+	return &info, nil
+}
+
+func (m *MemFS) Readlink(name string) (string, error) {
+	n, err := m.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if n.link == "" {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: syscall.EINVAL}
+	}
+	return n.link, nil
+}
+
+func (m *MemFS) Attr(info fs.FileInfo) FileAttr {
+	return FileAttr{
+		Links:      1,
+		AccessTime: info.ModTime(),
+		ChangeTime: info.ModTime(),
+	}
+}
+
+func (m *MemFS) Chdir(name string) error {
+	n, err := m.lookup("chdir", name, true)
+	if err != nil {
+		return err
+	}
+	if !n.info.IsDir() {
+		return &fs.PathError{Op: "chdir", Path: name, Err: syscall.ENOTDIR}
+	}
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(m.cwd, name)
+	}
+	m.cwd = filepath.Clean(name)
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+type MenuItem struct {
+	Text  string
+	Info  string
+	Value string
+}
+
+// Menu is a list drawn over the main and preview panes, for picking one of
+// a few things that are not files, e.g. bookmarks.
+type Menu struct {
+	overlayList
+
+	items    []MenuItem
+	onEnter  func(MenuItem)
+	onDelete func(MenuItem) error
+}
+
+func NewMenu(c *Controller, modes *Modes) *Menu {
+	m := &Menu{
+		overlayList: overlayList{
+			c:     c,
+			modes: modes,
+			mode:  "menu",
+		},
+	}
+	m.enter = m.Enter
+
+	m.addMode(map[string]CMD{
+		"enter":  m.Enter,
+		"delete": m.Delete,
+	}, menuKeybindings())
+	return m
+}
+
+func menuKeybindings() string {
+	binds := []string{
+		"ctrl-l:resize",
+		"j:next",
+		"k:prev",
+		"down:next",
+		"up:prev",
+		"ctrl-n:next",
+		"ctrl-p:prev",
+		"g:top",
+		"G:bottom",
+		"enter:enter",
+		"l:enter",
+		"d:delete",
+		"del:delete",
+		"q:quit",
+		"h:quit",
+		"esc:quit",
+		"ctrl-c:quit",
+	}
+	return strings.Join(binds, ",")
+}
+
+// Open shows items until one is entered or the menu is closed. onDelete
+// may be nil if items cannot be deleted.
+func (m *Menu) Open(title string, items []MenuItem, onEnter func(MenuItem), onDelete func(MenuItem) error) {
+	m.title = title
+	m.onEnter = onEnter
+	m.onDelete = onDelete
+	m.setItems(items)
+	m.open()
+}
+
+func (m *Menu) setItems(items []MenuItem) {
+	m.items = items
+	rows := make([]ListRow, 0, len(items))
+	for _, item := range items {
+		item := item
+		rows = append(rows, ListRow{
+			Left: func(bool) ListItem {
+				contents := ListItem{}
+				contents.WriteString(item.Text, nil)
+				return contents
+			},
+			Right: func(bool) ListItem {
+				st := m.Style.Foreground(tcell.ColorGray)
+				contents := ListItem{}
+				contents.WriteString(item.Info, &st)
+				return contents
+			},
+		})
+	}
+	m.view.List.UpdateRows(rows)
+}
+
+func (m *Menu) selected() (MenuItem, bool) {
+	i, ok := m.overlayList.selected(len(m.items))
+	if !ok {
+		return MenuItem{}, false
+	}
+	return m.items[i], true
+}
+
+func (m *Menu) Enter() {
+	item, ok := m.selected()
+	if !ok {
+		return
+	}
+	m.Close()
+	m.onEnter(item)
+}
+
+func (m *Menu) Delete() {
+	item, ok := m.selected()
+	if !ok || m.onDelete == nil {
+		return
+	}
+	if err := m.onDelete(item); err != nil {
+		m.c.Warn("%+v", err)
+		return
+	}
+
+	i := m.view.SelectAt
+	items := append(m.items[:i:i], m.items[i+1:]...)
+	m.setItems(items)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Modes are the keymaps keys are looked up in, one for each mode, along
+// with how they were bound. Modes are entered and left explicitly and nest,
+// e.g. a prompt over the pager over normal mode. The Dispatcher reads the
+// keymap of the current one.
+type Modes struct {
+	c      *Controller
+	keymap map[Event][]Action
+	modes  map[string]*Bindings
+	stack  []string
+}
+
+func NewModes(c *Controller) *Modes {
+	return &Modes{
+		c:     c,
+		modes: make(map[string]*Bindings),
+	}
+}
+
+// Add adds the mode name, its keys bound by bindings.
+func (m *Modes) Add(name string, bindings *Bindings) {
+	m.modes[name] = bindings
+}
+
+func (m *Modes) Get(name string) (*Bindings, error) {
+	bindings, ok := m.modes[name]
+	if !ok {
+		return nil, fmt.Errorf("no mode '%s'", name)
+	}
+	return bindings, nil
+}
+
+func (m *Modes) Names() []string {
+	names := make([]string, 0, len(m.modes))
+	for name := range m.modes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Keymap points to the keymap of the current mode, whichever it is.
+func (m *Modes) Keymap() *map[Event][]Action {
+	return &m.keymap
+}
+
+func (m *Modes) Current() string {
+	if len(m.stack) == 0 {
+		return ""
+	}
+	return m.stack[len(m.stack)-1]
+}
+
+// Enter switches to the mode name until it is left.
+func (m *Modes) Enter(name string) {
+	bindings, ok := m.modes[name]
+	if !ok {
+		log.Fatalf("unknown mode: %s", name)
+	}
+	m.stack = append(m.stack, name)
+	m.keymap = bindings.keymap
+}
+
+// Leave goes back to the mode the current one was entered from. The first
+// mode entered is never left.
+func (m *Modes) Leave() {
+	if len(m.stack) <= 1 {
+		return
+	}
+	m.stack = m.stack[:len(m.stack)-1]
+	m.keymap = m.modes[m.Current()].keymap
+}
+
+// Parse adds bindings to a mode, written as "<mode>:<bindings>", the
+// bindings as for Bindings.Parse, e.g. "filter:ctrl-j:next,ctrl-k:prev".
+func (m *Modes) Parse(str string) error {
+	name, keybindings, err := splitModeBindings(str)
+	if err != nil {
+		return err
+	}
+	bindings, err := m.Get(name)
+	if err != nil {
+		return err
+	}
+	return bindings.Parse(keybindings)
+}
+
+// splitModeBindings splits str as Parse does, checking that the bindings
+// are well formed. Whether the mode and the commands exist is only known
+// once they are added.
+func splitModeBindings(str string) (string, string, error) {
+	name, keybindings, ok := strings.Cut(str, ":")
+	if !ok {
+		return "", "", fmt.Errorf("missing mode in '%s'", str)
+	}
+	err := eachBinding(keybindings, func([]Event, string, Script, string) error {
+		return nil
+	})
+	return name, keybindings, err
+}
+
+func (m *Modes) bind(mode, key string, args []string) {
+	bindings, err := m.Get(mode)
+	if err == nil {
+		err = bindings.Map(key, joinScript(args))
+	}
+	if err != nil {
+		m.c.Warn("%v", err)
+	}
+}
+
+func (m *Modes) unbind(mode, key string) {
+	bindings, err := m.Get(mode)
+	if err == nil {
+		err = bindings.Unmap(key)
+	}
+	if err != nil {
+		m.c.Warn("%v", err)
+	}
+}
+
+// MapCMD is "map <key> <commands>" in normal mode, the commands joined by
+// joinScript.
+func (m *Modes) MapCMD(args []string) {
+	if len(args) < 2 {
+		m.c.Warn("Usage: map <key> <commands>")
+		return
+	}
+	m.bind("normal", args[0], args[1:])
+}
+
+func (m *Modes) UnmapCMD(args []string) {
+	if len(args) != 1 {
+		m.c.Warn("Usage: unmap <key>")
+		return
+	}
+	m.unbind("normal", args[0])
+}
+
+// BindModeCMD is "bind_mode <mode> <key> <commands>", as map in any mode.
+func (m *Modes) BindModeCMD(args []string) {
+	if len(args) < 3 {
+		m.c.Warn("Usage: bind_mode <mode> <key> <commands>")
+		return
+	}
+	m.bind(args[0], args[1], args[2:])
+}
+
+func (m *Modes) UnbindModeCMD(args []string) {
+	if len(args) != 2 {
+		m.c.Warn("Usage: unbind_mode <mode> <key>")
+		return
+	}
+	m.unbind(args[0], args[1])
+}
+
+// ListCMD lists the bindings of all modes with the commands they run,
+// normal mode first, the others prefixed by the mode. Entering one runs
+// them, deleting one unbinds it.
+func (m *Modes) ListCMD(menu *Menu) CMD {
+	return func(ev Event, keymap *map[Event][]Action, args []string) {
+		names := []string{"normal"}
+		for _, name := range m.Names() {
+			if name != "normal" {
+				names = append(names, name)
+			}
+		}
+
+		var items []MenuItem
+		for _, name := range names {
+			bindings := m.modes[name]
+			for _, key := range bindings.Keys() {
+				text := key
+				if name != "normal" {
+					text = name + ":" + key
+				}
+				items = append(items, MenuItem{
+					Text:  text,
+					Info:  bindings.Text(key),
+					Value: name + ":" + key,
+				})
+			}
+		}
+
+		menu.Open("Key bindings", items, func(item MenuItem) {
+			name, key, _ := strings.Cut(item.Value, ":")
+			keys, err := parseKeys(key)
+			if err != nil {
+				return
+			}
+			for _, action := range m.modes[name].keymap[seqEvent(keys)] {
+				action(keys[len(keys)-1], keymap)
+			}
+		}, func(item MenuItem) error {
+			name, key, _ := strings.Cut(item.Value, ":")
+			return m.modes[name].Unmap(key)
+		})
+	}
+}
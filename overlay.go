@@ -0,0 +1,121 @@
+package main
+
+import (
+	"log"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// addOverlayMode adds the mode name of something drawn over the panes, its
+// keys bound by keybindings to cmds. cmds has to have resize and mouse, run
+// on those events.
+func addOverlayMode(c *Controller, modes *Modes, name string, cmds map[string]CMD, keybindings string) {
+	keymap := make(map[Event][]Action)
+	keymap[Resize.AsEvent()] = []Action{ToAction(cmds["resize"], nil)}
+	keymap[Mouse.AsEvent()] = []Action{ToAction(cmds["mouse"], nil)}
+	bindings := NewBindings(c, keymap, cmds)
+	if err := bindings.Parse(keybindings); err != nil {
+		log.Fatalf("%+v", err)
+	}
+	modes.Add(name, bindings)
+}
+
+// overlayList is a list under a title, drawn over the main and preview panes
+// in a mode of its own, as Menu and SelectionView are.
+type overlayList struct {
+	c     *Controller
+	modes *Modes
+	mode  string
+	view  ListView
+	Style tcell.Style
+
+	title string
+	// Run by entering a row or double clicking it
+	enter func()
+}
+
+// addMode adds the mode of the list, its keys bound by keybindings to cmds
+// and to those moving in the list, quitting it and handling resize and the
+// mouse.
+func (o *overlayList) addMode(cmds map[string]CMD, keybindings string) {
+	for name, cmd := range map[string]CMD{
+		"resize": o.c.Resize,
+		"mouse":  o.HandleMouseEvent,
+		"quit":   o.Close,
+		"next":   func(count int, _ []string) { o.view.SelectAt += countOr(count, 1) },
+		"prev":   func(count int, _ []string) { o.view.SelectAt -= countOr(count, 1) },
+		"top":    func() { o.view.SelectAt = 0 },
+		"bottom": func() { o.view.SelectAt = o.view.List.Size() - 1 },
+	} {
+		cmds[name] = cmd
+	}
+	addOverlayMode(o.c, o.modes, o.mode, cmds, keybindings)
+}
+
+// open shows the list from its first row until closed.
+func (o *overlayList) open() {
+	o.view.SelectAt = 0
+	o.view.ViewBeginAt = 0
+	o.modes.Enter(o.mode)
+	o.c.SetOverlay(o)
+}
+
+func (o *overlayList) Close() {
+	o.modes.Leave()
+	o.c.SetOverlay(nil)
+	o.c.Resize()
+}
+
+// selected returns the row selected, if there is one of n rows.
+func (o *overlayList) selected(n int) (int, bool) {
+	if o.view.SelectAt < 0 || o.view.SelectAt >= n {
+		return 0, false
+	}
+	return o.view.SelectAt, true
+}
+
+func (o *overlayList) Draw() {
+	width, height := o.c.screen.Size()
+	win := &Win{
+		X1:     o.c.main.Win.X1,
+		X2:     width,
+		Y1:     1,
+		Y2:     height - 2,
+		Screen: o.c.screen,
+	}
+	win.Reset(o.Style)
+
+	titleSt := o.Style.Reverse(true)
+	title := make(ListItem, 0, win.W())
+	title.WriteString(" "+o.title, &titleSt)
+	for len(title) < win.W() {
+		title.WriteContent(' ', &titleSt)
+	}
+	win.Render(0, 0, title, o.Style, false)
+
+	o.view.Win = &Win{
+		X1:     win.X1,
+		X2:     win.X2,
+		Y1:     win.Y1 + 1,
+		Y2:     win.Y2,
+		Screen: o.c.screen,
+	}
+	o.view.Draw()
+}
+
+func (o *overlayList) HandleMouseEvent(ev Event, _ *map[Event][]Action, _ []string) {
+	me := ev.MouseEvent
+	if me == nil || o.view.Win == nil || !o.view.Win.In(me.X, me.Y) {
+		return
+	}
+
+	switch {
+	case me.S != 0:
+		o.view.ScrollDown(-me.S * 3)
+	case me.Double:
+		o.view.Select(me.Y)
+		o.enter()
+	case me.Down && me.Left:
+		o.view.Select(me.Y)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+const (
+	// Files are read into memory, bigger ones are truncated.
+	pagerMaxBytes = 16 * 1024 * 1024
+	hexLineBytes  = 16
+)
+
+type Pager struct {
+	c     *Controller
+	cli   *CLI
+	modes *Modes
+	Win   *Win
+	Style tcell.Style
+
+	path      string
+	data      []byte
+	truncated bool
+	lines     []string
+	hex       bool
+	numbers   bool
+	top       int
+	pattern   string
+}
+
+func NewPager(c *Controller, cli *CLI, modes *Modes) *Pager {
+	p := &Pager{
+		c:       c,
+		cli:     cli,
+		modes:   modes,
+		numbers: true,
+	}
+	addOverlayMode(c, modes, "pager", p.cmds(), pagerKeybindings())
+	return p
+}
+
+func (p *Pager) cmds() map[string]CMD {
+	scroll := func(factor float64) func(int, []string) {
+		return func(count int, args []string) {
+			n := ParseArgOrDefault(args, 0, 1).(int)
+			if factor != 0 {
+				n = int(float64(p.Win.H()) * factor)
+			}
+			p.ScrollDown(n * countOr(count, 1))
+		}
+	}
+
+	return map[string]CMD{
+		"resize":              p.c.Resize,
+		"mouse":               p.HandleMouseEvent,
+		"quit":                p.Close,
+		"down":                scroll(0),
+		"up":                  func(count int, args []string) { p.ScrollDown(-ParseArgOrDefault(args, 0, 1).(int) * countOr(count, 1)) },
+		"half_page_down":      scroll(0.5),
+		"half_page_up":        scroll(-0.5),
+		"page_down":           scroll(1),
+		"page_up":             scroll(-1),
+		"top":                 p.Top,
+		"bottom":              p.Bottom,
+		"search":              p.StartSearch,
+		"search_next":         func() { p.SearchNext(true) },
+		"search_prev":         func() { p.SearchNext(false) },
+		"toggle_hex":          p.ToggleHex,
+		"toggle_line_numbers": p.ToggleLineNumbers,
+	}
+}
+
+func pagerKeybindings() string {
+	binds := []string{
+		"ctrl-l:resize",
+		"j:down",
+		"k:up",
+		"down:down",
+		"up:up",
+		"enter:down",
+		"ctrl-e:down",
+		"ctrl-y:up",
+		"ctrl-d:half_page_down",
+		"ctrl-u:half_page_up",
+		"space:page_down",
+		"ctrl-f:page_down",
+		"pgdn:page_down",
+		"ctrl-b:page_up",
+		"pgup:page_up",
+		"g:top",
+		"G:bottom",
+		"/:search",
+		"n:search_next",
+		"N:search_prev",
+		"x:toggle_hex",
+		"#:toggle_line_numbers",
+		"q:quit",
+		"esc:quit",
+		"ctrl-c:quit",
+	}
+	return strings.Join(binds, ",")
+}
+
+// Open shows the selected file full screen until quit.
+func (p *Pager) Open() {
+	info := p.c.Selected()
+	if info == nil {
+		return
+	}
+	if !info.Mode().IsRegular() {
+		p.c.Warn("%s is not a regular file", info.Name())
+		return
+	}
+
+	data, err := readHead(p.c.fsys, info.Path, pagerMaxBytes+1)
+	if err != nil {
+		p.c.Warn("%+v", err)
+		return
+	}
+
+	p.path = info.Path
+	p.truncated = len(data) > pagerMaxBytes
+	if p.truncated {
+		data = data[:pagerMaxBytes]
+	}
+	p.data = data
+	p.hex = isBinary(data)
+	p.lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	for i, line := range p.lines {
+		p.lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	p.top = 0
+
+	p.modes.Enter("pager")
+	p.c.SetOverlay(p)
+}
+
+func (p *Pager) Close() {
+	p.modes.Leave()
+	p.data = nil
+	p.lines = nil
+	p.c.SetOverlay(nil)
+	p.c.Resize()
+}
+
+func (p *Pager) lineCount() int {
+	if p.hex {
+		return (len(p.data) + hexLineBytes - 1) / hexLineBytes
+	}
+	return len(p.lines)
+}
+
+func (p *Pager) line(i int) string {
+	if !p.hex {
+		return p.lines[i]
+	}
+
+	chunk := p.data[i*hexLineBytes : min(len(p.data), (i+1)*hexLineBytes)]
+	var b strings.Builder
+	fmt.Fprintf(&b, "%08x  ", i*hexLineBytes)
+	for j := 0; j < hexLineBytes; j++ {
+		if j < len(chunk) {
+			fmt.Fprintf(&b, "%02x ", chunk[j])
+		} else {
+			b.WriteString("   ")
+		}
+		if j == hexLineBytes/2-1 {
+			b.WriteByte(' ')
+		}
+	}
+	b.WriteString(" |")
+	for _, c := range chunk {
+		if c >= 0x20 && c < 0x7f {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('.')
+		}
+	}
+	b.WriteByte('|')
+	return b.String()
+}
+
+func (p *Pager) Draw() {
+	width, height := p.c.screen.Size()
+	// Leave the bottom line to the CLI for search and warnings
+	p.Win = &Win{
+		X1:     0,
+		X2:     width,
+		Y1:     0,
+		Y2:     height - 2,
+		Screen: p.c.screen,
+	}
+	p.Win.Reset(p.Style)
+	p.clamp()
+
+	n := p.lineCount()
+	headerSt := p.Style.Reverse(true)
+	header := p.path
+	if p.truncated {
+		header += " (truncated)"
+	}
+	pos := fmt.Sprintf("%d-%d/%d", min(n, p.top+1), min(n, p.top+p.Win.H()), n)
+	contents := make(ListItem, 0, width)
+	contents.WriteString(header, &headerSt)
+	for len(contents) < width-len(pos)-1 {
+		contents.WriteContent(' ', &headerSt)
+	}
+	contents.WriteString(" "+pos, &headerSt)
+	p.Win.Render(0, 0, contents, p.Style, false)
+
+	numWidth := len(fmt.Sprint(n))
+	numSt := p.Style.Foreground(tcell.ColorGray)
+	matchSt := p.Style.Reverse(true)
+	for row := 1; row <= p.Win.H(); row++ {
+		i := p.top + row - 1
+		if i >= n {
+			break
+		}
+
+		contents := make(ListItem, 0, width)
+		if p.numbers && !p.hex {
+			contents.WriteString(fmt.Sprintf("%*d ", numWidth, i+1), &numSt)
+		}
+		p.writeLine(&contents, p.line(i), &matchSt)
+		p.Win.Render(0, row, contents, p.Style, false)
+	}
+}
+
+// writeLine expands tabs, escapes control characters and highlights
+// matches of the search pattern.
+func (p *Pager) writeLine(contents *ListItem, line string, matchSt *tcell.Style) {
+	var matched []bool
+	if p.pattern != "" {
+		matched = make([]bool, len(line))
+		for off := 0; ; {
+			i := strings.Index(line[off:], p.pattern)
+			if i == -1 {
+				break
+			}
+			for j := off + i; j < off+i+len(p.pattern); j++ {
+				matched[j] = true
+			}
+			off += i + len(p.pattern)
+		}
+	}
+
+	col := 0
+	for i, r := range line {
+		var st *tcell.Style
+		if matched != nil && matched[i] {
+			st = matchSt
+		}
+
+		switch {
+		case r == '\t':
+			for n := 4 - col%4; n > 0; n-- {
+				contents.WriteContent(' ', st)
+				col++
+			}
+		case unicode.IsControl(r):
+			contents.WriteContent('?', st)
+			col++
+		default:
+			contents.WriteContent(r, st)
+			col++
+		}
+	}
+}
+
+func (p *Pager) clamp() {
+	if max := p.lineCount() - p.Win.H(); p.top > max {
+		p.top = max
+	}
+	if p.top < 0 {
+		p.top = 0
+	}
+}
+
+func (p *Pager) ScrollDown(n int) {
+	p.top += n
+}
+
+func (p *Pager) Top() {
+	p.top = 0
+}
+
+func (p *Pager) Bottom() {
+	p.top = p.lineCount()
+}
+
+func (p *Pager) ToggleHex() {
+	// Keep roughly the same position in the file
+	if p.hex {
+		p.top = strings.Count(string(p.data[:min(len(p.data), p.top*hexLineBytes)]), "\n")
+	} else {
+		off := 0
+		for i := 0; i < p.top && i < len(p.lines); i++ {
+			off += len(p.lines[i]) + 1
+		}
+		p.top = off / hexLineBytes
+	}
+	p.hex = !p.hex
+}
+
+func (p *Pager) ToggleLineNumbers() {
+	p.numbers = !p.numbers
+}
+
+func (p *Pager) StartSearch() {
+	p.cli.StartPrompt('/', func(pattern string) {
+		p.pattern = pattern
+		if pattern != "" {
+			p.search(p.top, true)
+		}
+	})
+}
+
+func (p *Pager) SearchNext(forward bool) {
+	if p.pattern == "" {
+		return
+	}
+	if forward {
+		p.search(p.top+1, true)
+	} else {
+		p.search(p.top-1, false)
+	}
+}
+
+// search moves to the first line matching the pattern, starting at line
+// from and wrapping around.
+func (p *Pager) search(from int, forward bool) {
+	n := p.lineCount()
+	for k := 0; k < n; k++ {
+		i := from + k
+		if !forward {
+			i = from - k
+		}
+		i = (i%n + n) % n
+		if strings.Contains(p.line(i), p.pattern) {
+			p.top = i
+			return
+		}
+	}
+	p.c.Warn("Pattern not found: %s", p.pattern)
+}
+
+func (p *Pager) HandleMouseEvent(ev Event, _ *map[Event][]Action, _ []string) {
+	if ev.MouseEvent == nil {
+		return
+	}
+	p.ScrollDown(-ev.MouseEvent.S * 3)
+}
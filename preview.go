@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+const (
+	// Only the head of a file is read, enough for a screenful of text.
+	previewMaxBytes = 64 * 1024
+	// The preview pane is hidden when the screen is narrower than this.
+	previewMinWidth = 60
+	// Output of a preview command is cut off after this many lines.
+	previewMaxLines = 5000
+)
+
+type PreviewEvent struct {
+	Path   string
+	IsDir  bool
+	Lines  []string
+	Binary bool
+	Err    error
+}
+
+type Previewer struct {
+	fsys    FS
+	eventCh chan PreviewEvent
+	cancel  context.CancelFunc
+
+	// Command is run through the shell for every selected entry, with {}
+	// replaced by its quoted path. The built-in previewer is used if it is
+	// empty.
+	Command string
+	Timeout time.Duration
+}
+
+func NewPreviewer(fsys FS, command string, timeout time.Duration) *Previewer {
+	return &Previewer{
+		fsys:    fsys,
+		eventCh: make(chan PreviewEvent, 1),
+		Command: command,
+		Timeout: timeout,
+	}
+}
+
+func (p *Previewer) Event() <-chan PreviewEvent {
+	return p.eventCh
+}
+
+// Load previews path in the background, cancelling the previous preview.
+// Without a preview command, the first lines of files are read and
+// directories are only reported as such, listing them is left to the DirSet.
+func (p *Previewer) Load(path string, width, height int) {
+	p.Cancel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	go func() {
+		var ev PreviewEvent
+		if p.Command != "" {
+			ev = p.run(ctx, path, width, height)
+		} else {
+			ev = loadPreview(p.fsys, path, height)
+		}
+
+		select {
+		case p.eventCh <- ev:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+func (p *Previewer) Cancel() {
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+}
+
+func (p *Previewer) run(ctx context.Context, path string, width, height int) PreviewEvent {
+	ev := PreviewEvent{Path: path}
+
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.Command("sh", "-c", strings.ReplaceAll(p.Command, "{}", quoteShell(path)))
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("PF_PREVIEW_LINES=%d", height),
+		fmt.Sprintf("PF_PREVIEW_COLUMNS=%d", width))
+	// Own process group, so that children of the shell are killed too
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		ev.Err = err
+		return ev
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		ev.Err = err
+		return ev
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-done:
+		}
+	}()
+
+	scanner := bufio.NewScanner(stdout)
+	for len(ev.Lines) < previewMaxLines && scanner.Scan() {
+		ev.Lines = append(ev.Lines, strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		// E.g. a line too long. The rest is not read, so the command
+		// would block writing it.
+		log.Printf("preview %s: %+v", path, err)
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	} else if len(ev.Lines) == previewMaxLines {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	if err := cmd.Wait(); err != nil {
+		log.Printf("preview %s: %+v", path, err)
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		ev.Err = fmt.Errorf("preview timed out after %v", p.Timeout)
+	}
+	return ev
+}
+
+// quoteShell quotes s for sh in single quotes, as fzf does for {}.
+func quoteShell(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func loadPreview(fsys FS, path string, lines int) PreviewEvent {
+	ev := PreviewEvent{Path: path}
+
+	fi, err := fsys.Stat(path)
+	if err != nil {
+		ev.Err = err
+		return ev
+	}
+	if fi.IsDir() || fi.Mode().IsRegular() && isArchiveName(path) {
+		ev.IsDir = true
+		return ev
+	}
+	if !fi.Mode().IsRegular() {
+		// Reading fifos or devices may block forever
+		ev.Lines = []string{fi.Mode().String()}
+		return ev
+	}
+
+	buf, err := readHead(fsys, path, previewMaxBytes)
+	if err != nil {
+		ev.Err = err
+		return ev
+	}
+	if isBinary(buf) {
+		ev.Binary = true
+		return ev
+	}
+
+	ev.Lines = strings.SplitN(string(buf), "\n", lines+1)
+	if len(ev.Lines) > lines {
+		ev.Lines = ev.Lines[:lines]
+	}
+	for i, line := range ev.Lines {
+		ev.Lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return ev
+}
+
+// isBinary reports whether buf looks like the head of a binary file, that is
+// it contains a NUL byte or is not valid UTF-8.
+func isBinary(buf []byte) bool {
+	if bytes.IndexByte(buf, 0) != -1 {
+		return true
+	}
+
+	// The read may have split the last rune
+	for i := 0; i < utf8.UTFMax && len(buf) > 0; i++ {
+		if r, _ := utf8.DecodeLastRune(buf); r != utf8.RuneError {
+			break
+		}
+		buf = buf[:len(buf)-1]
+	}
+	return !utf8.Valid(buf)
+}
+
+type PreviewView struct {
+	Win    *Win
+	List   ListView
+	Path   string
+	IsDir  bool
+	Lines  []string
+	Offset int
+	Binary bool
+	Err    error
+	Style  tcell.Style
+}
+
+func (v *PreviewView) Clear(path string) {
+	v.Path = path
+	v.IsDir = false
+	v.Lines = nil
+	v.Offset = 0
+	v.Binary = false
+	v.Err = nil
+	v.List.List.UpdateRows(nil)
+	v.List.SelectAt = 0
+	v.List.ViewBeginAt = 0
+}
+
+func (v *PreviewView) Draw() {
+	if v.IsDir {
+		v.List.Win = v.Win
+		v.List.Draw()
+		return
+	}
+
+	v.Win.Reset(v.Style)
+
+	if v.Binary {
+		v.Win.RenderANSI(1, 0, "binary file", v.Style.Foreground(tcell.ColorGray))
+		return
+	}
+
+	row := 0
+	for i := v.Offset; i < len(v.Lines) && row <= v.Win.H(); i++ {
+		v.Win.RenderANSI(1, row, v.Lines[i], v.Style)
+		row++
+	}
+	if v.Err != nil && row <= v.Win.H() {
+		v.Win.RenderANSI(1, row, fmt.Sprintf("%v", v.Err), v.Style.Foreground(tcell.ColorRed))
+	}
+}
+
+func (v *PreviewView) ScrollDown(n int) {
+	if v.IsDir {
+		v.List.ScrollDown(n)
+		return
+	}
+
+	v.Offset += n
+	if max := len(v.Lines) - v.Win.H() - 1; v.Offset > max {
+		v.Offset = max
+	}
+	if v.Offset < 0 {
+		v.Offset = 0
+	}
+}
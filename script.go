@@ -0,0 +1,516 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Commands, in key bindings and in : mode, are written like in a shell:
+//
+//	dir filter 'a b'; next
+//
+// Words are split by spaces, which are kept inside quotes or after a
+// backslash. Commands are run one after the other, separated by ';'.
+// Arguments are expanded each time the commands run, outside of single
+// quotes:
+//
+//	$NAME, ${NAME}  the environment variable NAME
+//	%f              the path of the selected file
+//	%F              the paths of the marked files, one argument each, in
+//	                the output order
+//	%d              the current directory
+//	%%              a '%'
+
+// Vars gives the values of the %-substitutions.
+type Vars interface {
+	Var(name rune) []string
+}
+
+type ParseError struct {
+	Input string
+	Pos   int
+	Msg   string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at column %d of '%s'", e.Msg, e.Pos+1, e.Input)
+}
+
+// wordPart is literal text, or a substitution if sub is set.
+type wordPart struct {
+	text   string
+	sub    rune // '$' for the environment variable text, else the % one
+	quoted bool
+}
+
+type Word []wordPart
+
+type Command struct {
+	Name string
+	Args []Word
+}
+
+type Script []Command
+
+type scriptParser struct {
+	input string
+	rs    []rune
+	pos   int
+	// stop reports whether an unquoted r ends the script
+	stop func(r rune) bool
+}
+
+func (p *scriptParser) errorf(pos int, format string, a ...any) error {
+	return &ParseError{Input: p.input, Pos: pos, Msg: fmt.Sprintf(format, a...)}
+}
+
+func (p *scriptParser) done() bool {
+	return p.pos >= len(p.rs) || (p.stop != nil && p.stop(p.rs[p.pos]))
+}
+
+// ParseScript parses commands separated by ';'.
+func ParseScript(s string) (Script, error) {
+	p := &scriptParser{input: s, rs: []rune(s)}
+	return p.script()
+}
+
+func (p *scriptParser) script() (Script, error) {
+	start := p.pos
+	var script Script
+	for {
+		cmd, err := p.command()
+		if err != nil {
+			return nil, err
+		}
+		if cmd != nil {
+			script = append(script, *cmd)
+		}
+		if p.done() {
+			break
+		}
+		// ';'
+		p.pos++
+	}
+	if len(script) == 0 {
+		return nil, p.errorf(start, "no command")
+	}
+	return script, nil
+}
+
+// command parses the words up to ';' or the end. It is nil if there are
+// none.
+func (p *scriptParser) command() (*Command, error) {
+	var words []Word
+	var namePos int
+	for {
+		for !p.done() && unicode.IsSpace(p.rs[p.pos]) {
+			p.pos++
+		}
+		if p.done() || p.rs[p.pos] == ';' {
+			break
+		}
+		if len(words) == 0 {
+			namePos = p.pos
+		}
+		word, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+	}
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	var name strings.Builder
+	for _, part := range words[0] {
+		if part.sub != 0 {
+			return nil, p.errorf(namePos, "substitution in command name")
+		}
+		name.WriteString(part.text)
+	}
+	return &Command{Name: name.String(), Args: words[1:]}, nil
+}
+
+func (p *scriptParser) word() (Word, error) {
+	var word Word
+	var lit strings.Builder
+	flush := func(quoted bool) {
+		if lit.Len() != 0 {
+			word = append(word, wordPart{text: lit.String(), quoted: quoted})
+			lit.Reset()
+		}
+	}
+
+	for !p.done() {
+		r := p.rs[p.pos]
+		switch {
+		case unicode.IsSpace(r), r == ';':
+			flush(false)
+			return word, nil
+		case r == '\\':
+			if p.pos+1 >= len(p.rs) {
+				return nil, p.errorf(p.pos, "trailing backslash")
+			}
+			lit.WriteRune(p.rs[p.pos+1])
+			p.pos += 2
+		case r == '\'':
+			start := p.pos
+			p.pos++
+			for p.pos < len(p.rs) && p.rs[p.pos] != '\'' {
+				lit.WriteRune(p.rs[p.pos])
+				p.pos++
+			}
+			if p.pos >= len(p.rs) {
+				return nil, p.errorf(start, "unterminated quote")
+			}
+			p.pos++
+			// An empty '' is still a word
+			if lit.Len() == 0 {
+				word = append(word, wordPart{quoted: true})
+			}
+		case r == '"':
+			flush(false)
+			if err := p.doubleQuoted(&word); err != nil {
+				return nil, err
+			}
+		case r == '$' || r == '%':
+			part, ok, err := p.substitution()
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				flush(false)
+				word = append(word, part)
+			} else {
+				lit.WriteRune(r)
+				p.pos++
+			}
+		default:
+			lit.WriteRune(r)
+			p.pos++
+		}
+	}
+	flush(false)
+	return word, nil
+}
+
+// doubleQuoted parses "...", in which substitutions are done and a
+// backslash only escapes '"', '\', '$' and '%'.
+func (p *scriptParser) doubleQuoted(word *Word) error {
+	start := p.pos
+	p.pos++
+
+	var lit strings.Builder
+	flush := func() {
+		*word = append(*word, wordPart{text: lit.String(), quoted: true})
+		lit.Reset()
+	}
+
+	for p.pos < len(p.rs) {
+		r := p.rs[p.pos]
+		switch {
+		case r == '"':
+			p.pos++
+			flush()
+			return nil
+		case r == '\\' && p.pos+1 < len(p.rs) && strings.ContainsRune(`"\$%`, p.rs[p.pos+1]):
+			lit.WriteRune(p.rs[p.pos+1])
+			p.pos += 2
+		case r == '$' || r == '%':
+			part, ok, err := p.substitution()
+			if err != nil {
+				return err
+			}
+			if ok {
+				flush()
+				part.quoted = true
+				*word = append(*word, part)
+			} else {
+				lit.WriteRune(r)
+				p.pos++
+			}
+		default:
+			lit.WriteRune(r)
+			p.pos++
+		}
+	}
+	return p.errorf(start, "unterminated quote")
+}
+
+func isVarNameRune(r rune, first bool) bool {
+	return r == '_' || unicode.IsLetter(r) || (!first && unicode.IsDigit(r))
+}
+
+// substitution parses the $ or % at pos. It is not ok if a $ is not
+// followed by a name, it is then taken literally.
+func (p *scriptParser) substitution() (wordPart, bool, error) {
+	start := p.pos
+	if p.rs[p.pos] == '%' {
+		if p.pos+1 >= len(p.rs) {
+			return wordPart{}, false, p.errorf(start, "missing substitution after '%%'")
+		}
+		r := p.rs[p.pos+1]
+		p.pos += 2
+		switch r {
+		case '%':
+			return wordPart{text: "%"}, true, nil
+		case 'f', 'F', 'd':
+			return wordPart{sub: r}, true, nil
+		}
+		return wordPart{}, false, p.errorf(start, "unknown substitution '%%%c'", r)
+	}
+
+	i := p.pos + 1
+	if i < len(p.rs) && p.rs[i] == '{' {
+		j := i + 1
+		for j < len(p.rs) && p.rs[j] != '}' {
+			j++
+		}
+		if j >= len(p.rs) {
+			return wordPart{}, false, p.errorf(start, "unterminated '${'")
+		}
+		p.pos = j + 1
+		return wordPart{text: string(p.rs[i+1 : j]), sub: '$'}, true, nil
+	}
+
+	j := i
+	for j < len(p.rs) && isVarNameRune(p.rs[j], j == i) {
+		j++
+	}
+	if j == i {
+		return wordPart{}, false, nil
+	}
+	p.pos = j
+	return wordPart{text: string(p.rs[i:j]), sub: '$'}, true, nil
+}
+
+// Expand returns the arguments w stands for. An unquoted substitution
+// making up the whole word can stand for none or several of them.
+func (w Word) Expand(vars Vars) []string {
+	value := func(part wordPart) []string {
+		switch part.sub {
+		case 0:
+			return []string{part.text}
+		case '$':
+			return []string{os.Getenv(part.text)}
+		}
+		return vars.Var(part.sub)
+	}
+
+	if len(w) == 1 && !w[0].quoted && w[0].sub != 0 {
+		return value(w[0])
+	}
+
+	var sb strings.Builder
+	for _, part := range w {
+		sb.WriteString(strings.Join(value(part), " "))
+	}
+	return []string{sb.String()}
+}
+
+// wordSpecials are the runes quoteWord quotes words for.
+const wordSpecials = " \t\n;,'\"\\$%"
+
+// quoteWord quotes s if needed, so that it parses back to one word.
+func quoteWord(s string) string {
+	if s != "" && !strings.ContainsAny(s, wordSpecials) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// splitTyped splits s, a script as far as it is typed, into the words of
+// its last command, for completing the last of them. The words are split
+// and unquoted as by ParseScript, but substitutions are kept as written.
+// start is where the last word begins in s, and quote is the quote it
+// is left in, or '\\' after a trailing backslash.
+func splitTyped(s string) (words []string, start int, quote rune) {
+	var word strings.Builder
+	inWord, escaped := false, false
+	for i, r := range s {
+		if quote == 0 && !escaped && (unicode.IsSpace(r) || r == ';') {
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			if r == ';' {
+				words = nil
+			}
+			continue
+		}
+		if !inWord {
+			inWord = true
+			start = i
+		}
+
+		switch {
+		case escaped:
+			// Inside "...", only some runes are escaped
+			if quote == '"' && !strings.ContainsRune(`"\$%`, r) {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+		case r == '\'' || r == '"':
+			if quote == 0 {
+				quote = r
+			} else if quote == r {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		default:
+			word.WriteRune(r)
+		}
+	}
+
+	if !inWord {
+		start = len(s)
+	}
+	if escaped {
+		quote = '\\'
+	}
+	return append(words, word.String()), start, quote
+}
+
+// quoteTyped quotes s to be typed after a word left in quote, as returned
+// by splitTyped, so that it parses back to s.
+func quoteTyped(s string, quote rune) string {
+	switch quote {
+	case '\'':
+		return strings.ReplaceAll(s, "'", `'\''`)
+	case '"':
+		var sb strings.Builder
+		for _, r := range s {
+			if strings.ContainsRune(`"\$%`, r) {
+				sb.WriteRune('\\')
+			}
+			sb.WriteRune(r)
+		}
+		return sb.String()
+	}
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(wordSpecials, r) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// joinScript turns the arguments of commands taking commands, like alias,
+// back into a script. A single argument is taken as the script as it is,
+// so that "alias x 'a; b'" runs two commands, otherwise the arguments make
+// up one command.
+func joinScript(args []string) string {
+	if len(args) == 1 {
+		return args[0]
+	}
+	words := make([]string, 0, len(args))
+	for _, arg := range args {
+		words = append(words, quoteWord(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+// warner is implemented by Vars that can also show errors, as the
+// Controller does.
+type warner interface {
+	Warn(format string, a ...any)
+}
+
+// argChecks check the arguments of the commands taking only some words, so
+// that mistakes are reported when a script is compiled, by :map, alias or
+// the config file, rather than each time it runs. Arguments with
+// substitutions are only known then, and are left to the command.
+var argChecks = map[string]func(args []string) error{
+	"dir": func(args []string) error {
+		_, err := ParseDirCMDs(args)
+		return err
+	},
+	"toggle_dir_info": checkDirInfoColumns,
+}
+
+// literalArgs are the arguments of c, unless some have substitutions.
+func (c Command) literalArgs() ([]string, bool) {
+	args := make([]string, 0, len(c.Args))
+	for _, word := range c.Args {
+		var sb strings.Builder
+		for _, part := range word {
+			if part.sub != 0 {
+				return nil, false
+			}
+			sb.WriteString(part.text)
+		}
+		args = append(args, sb.String())
+	}
+	return args, true
+}
+
+// Check reports the first command of s not in cmds, or with arguments
+// refused by its argChecks.
+func (s Script) Check(cmds map[string]CMD) error {
+	for _, command := range s {
+		if _, ok := cmds[command.Name]; !ok {
+			return fmt.Errorf("unknown command '%s'", command.Name)
+		}
+		check, ok := argChecks[command.Name]
+		if !ok {
+			continue
+		}
+		if args, ok := command.literalArgs(); ok {
+			if err := check(args); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Compile turns s into an action running its commands from cmds. The
+// arguments are expanded each time it runs.
+func (s Script) Compile(cmds map[string]CMD, vars Vars) (Action, error) {
+	if err := s.Check(cmds); err != nil {
+		return nil, err
+	}
+
+	return func(ev Event, keymap *map[Event][]Action) {
+		for _, command := range s {
+			// Aliases may have been removed since
+			cmd, ok := cmds[command.Name]
+			if !ok {
+				if w, ok := vars.(warner); ok {
+					w.Warn("Unknown command '%s'", command.Name)
+				}
+				return
+			}
+			var args []string
+			for _, word := range command.Args {
+				args = append(args, word.Expand(vars)...)
+			}
+			ToAction(cmd, args)(ev, keymap)
+		}
+	}, nil
+}
+
+// CompileScript parses s and compiles it.
+func CompileScript(s string, cmds map[string]CMD, vars Vars) (Action, error) {
+	script, err := ParseScript(s)
+	if err != nil {
+		return nil, err
+	}
+	return script.Compile(cmds, vars)
+}
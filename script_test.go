@@ -0,0 +1,173 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testVars substitutes %f with "f f", %F with two marks and %d with none.
+type testVars struct{}
+
+func (testVars) Var(name rune) []string {
+	switch name {
+	case 'f':
+		return []string{"f f"}
+	case 'F':
+		return []string{"m1", "m 2"}
+	}
+	return nil
+}
+
+// expandScript returns the name and the expanded arguments of each
+// command of s.
+func expandScript(s Script) [][]string {
+	var commands [][]string
+	for _, command := range s {
+		words := []string{command.Name}
+		for _, word := range command.Args {
+			words = append(words, word.Expand(testVars{})...)
+		}
+		commands = append(commands, words)
+	}
+	return commands
+}
+
+func TestParseScript(t *testing.T) {
+	t.Setenv("PF_TEST", "v a")
+
+	tests := []struct {
+		s    string
+		want [][]string
+	}{
+		{"next", [][]string{{"next"}}},
+		{"  dir   sort_by_size  ", [][]string{{"dir", "sort_by_size"}}},
+		{"mark; next", [][]string{{"mark"}, {"next"}}},
+		{"mark;next;;", [][]string{{"mark"}, {"next"}}},
+		{"dir filter 'a b'", [][]string{{"dir", "filter", "a b"}}},
+		{`dir filter a\ b`, [][]string{{"dir", "filter", "a b"}}},
+		{`x 'a;b' "c;d" e\;f`, [][]string{{"x", "a;b", "c;d", "e;f"}}},
+		{`x '' ""`, [][]string{{"x", "", ""}}},
+		{`x a'b'"c"`, [][]string{{"x", "abc"}}},
+		{`x 'it'\''s'`, [][]string{{"x", "it's"}}},
+		{`x "a\"b\\c\d"`, [][]string{{"x", `a"b\c\d`}}},
+		// Substitutions
+		{"x $PF_TEST ${PF_TEST}b", [][]string{{"x", "v a", "v ab"}}},
+		{"x '$PF_TEST'", [][]string{{"x", "$PF_TEST"}}},
+		{`x "$PF_TEST" \$PF_TEST`, [][]string{{"x", "v a", "$PF_TEST"}}},
+		{"x $ $1 a$", [][]string{{"x", "$", "$1", "a$"}}},
+		{"x %f %F %d", [][]string{{"x", "f f", "m1", "m 2"}}},
+		{`x "%F" a%f %%`, [][]string{{"x", "m1 m 2", "af f", "%"}}},
+		{"x '%f'", [][]string{{"x", "%f"}}},
+	}
+	for _, test := range tests {
+		script, err := ParseScript(test.s)
+		if err != nil {
+			t.Errorf("ParseScript(%q): %v", test.s, err)
+			continue
+		}
+		if got := expandScript(script); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseScript(%q) = %q, want %q", test.s, got, test.want)
+		}
+	}
+}
+
+func TestParseScriptErrors(t *testing.T) {
+	tests := []struct {
+		s   string
+		pos int
+	}{
+		{"", 0},
+		{" ; ", 0},
+		{"x 'a", 2},
+		{`x "a`, 2},
+		{`x a\`, 3},
+		{"x %q", 2},
+		{"x %", 2},
+		{"x ${a", 2},
+		{"$X a", 0},
+		{"a; %f", 3},
+	}
+	for _, test := range tests {
+		_, err := ParseScript(test.s)
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("ParseScript(%q) = %v, want a ParseError", test.s, err)
+			continue
+		}
+		if perr.Pos != test.pos {
+			t.Errorf("ParseScript(%q) fails at %d, want %d: %v", test.s, perr.Pos, test.pos, err)
+		}
+	}
+}
+
+func TestQuoteWord(t *testing.T) {
+	for _, s := range []string{"a", "", "a b", "it's", `"$x"`, `a\b`, "a;b,c", "%f", "a\tb"} {
+		quoted := quoteWord(s)
+		script, err := ParseScript("x " + quoted)
+		if err != nil {
+			t.Errorf("quoteWord(%q) = %s: %v", s, quoted, err)
+			continue
+		}
+		if got := expandScript(script); !reflect.DeepEqual(got, [][]string{{"x", s}}) {
+			t.Errorf("quoteWord(%q) = %s, parsed back as %q", s, quoted, got)
+		}
+	}
+	if got := quoteWord("plain"); got != "plain" {
+		t.Errorf("quoteWord(plain) = %s", got)
+	}
+}
+
+func TestJoinScript(t *testing.T) {
+	tests := []struct {
+		args []string
+		want [][]string
+	}{
+		{[]string{"mark; next"}, [][]string{{"mark"}, {"next"}}},
+		{[]string{"dir", "filter a b"}, [][]string{{"dir", "filter a b"}}},
+		{[]string{"cd", "it's; x"}, [][]string{{"cd", "it's; x"}}},
+	}
+	for _, test := range tests {
+		script, err := ParseScript(joinScript(test.args))
+		if err != nil {
+			t.Errorf("joinScript(%q): %v", test.args, err)
+			continue
+		}
+		if got := expandScript(script); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("joinScript(%q) parsed back as %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+func TestScriptCheck(t *testing.T) {
+	cmds := map[string]CMD{
+		"next":            func() {},
+		"dir":             func([]string) {},
+		"toggle_dir_info": func([]string) {},
+	}
+
+	tests := []struct {
+		s  string
+		ok bool
+	}{
+		{"next", true},
+		{"next; bogus", false},
+		{"dir sort_by_size mtime", true},
+		{"dir filter any words", true},
+		{"dir bogus", false},
+		{"next; dir 'no_time' bogus", false},
+		{"toggle_dir_info perm hsize", true},
+		{"toggle_dir_info perm bogus", false},
+		// Only known when run
+		{"dir $X", true},
+		{"dir %f", true},
+	}
+	for _, test := range tests {
+		script, err := ParseScript(test.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := script.Check(cmds); (err == nil) != test.ok {
+			t.Errorf("Check(%q) = %v", test.s, err)
+		}
+	}
+}
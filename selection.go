@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SelectionOrder is the order the paths of a Selection are output in.
+type SelectionOrder int
+
+const (
+	// As marked, or moved since
+	SelectionOrderMark SelectionOrder = iota
+	SelectionOrderPath
+	// As the paths would be listed walking the directories sorted by name
+	SelectionOrderListing
+)
+
+var selectionOrders = []string{"mark", "path", "listing"}
+
+func ParseSelectionOrder(s string) (SelectionOrder, error) {
+	for i, name := range selectionOrders {
+		if s == name {
+			return SelectionOrder(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown order '%s', want one of %s", s, strings.Join(selectionOrders, ", "))
+}
+
+func (o SelectionOrder) String() string {
+	return selectionOrders[o]
+}
+
+// Selection is the set of paths marked, across directories, in the order
+// they were marked unless moved since.
+type Selection struct {
+	paths []string
+	index map[string]int // Of each path in paths
+	below map[string]int // Of each directory, how many paths in are below it
+	order SelectionOrder
+	// Of each path in the output order, from 1. Made as needed.
+	numbers map[string]int
+}
+
+func NewSelection() *Selection {
+	return &Selection{
+		index: make(map[string]int),
+		below: make(map[string]int),
+	}
+}
+
+func (s *Selection) Has(path string) bool {
+	_, ok := s.index[path]
+	return ok
+}
+
+// Index returns the position of path in the selection, from 0.
+func (s *Selection) Index(path string) (int, bool) {
+	i, ok := s.index[path]
+	return i, ok
+}
+
+// Below returns how many paths in the selection are below dir, at any
+// depth.
+func (s *Selection) Below(dir string) int {
+	return s.below[dir]
+}
+
+// addBelow adds n to the count below each directory path is in.
+func (s *Selection) addBelow(path string, n int) {
+	dir := path
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return
+		}
+		dir = parent
+		if s.below[dir] += n; s.below[dir] == 0 {
+			delete(s.below, dir)
+		}
+	}
+}
+
+func (s *Selection) Order() SelectionOrder {
+	return s.order
+}
+
+func (s *Selection) SetOrder(order SelectionOrder) {
+	s.order = order
+	s.numbers = nil
+}
+
+// Sorted returns the paths in the output order.
+func (s *Selection) Sorted() []string {
+	paths := s.Paths()
+	switch s.order {
+	case SelectionOrderPath:
+		sort.Strings(paths)
+	case SelectionOrderListing:
+		sort.SliceStable(paths, func(i, j int) bool {
+			return lessListed(paths[i], paths[j])
+		})
+	}
+	return paths
+}
+
+// lessListed reports whether path a is listed before b, walking the
+// directories sorted by name.
+func lessListed(a, b string) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		if lessLower(as[i], bs[i]) != lessLower(bs[i], as[i]) {
+			return lessLower(as[i], bs[i])
+		}
+		// Equal but for case
+		return as[i] < bs[i]
+	}
+	return len(as) < len(bs)
+}
+
+// Number returns the position of path in the output order, from 1.
+func (s *Selection) Number(path string) (int, bool) {
+	if s.order == SelectionOrderMark {
+		i, ok := s.index[path]
+		return i + 1, ok
+	}
+
+	if s.numbers == nil {
+		s.numbers = make(map[string]int, len(s.paths))
+		for i, path := range s.Sorted() {
+			s.numbers[path] = i + 1
+		}
+	}
+	n, ok := s.numbers[path]
+	return n, ok
+}
+
+func (s *Selection) Len() int {
+	return len(s.paths)
+}
+
+// Paths returns the paths, in order.
+func (s *Selection) Paths() []string {
+	return append([]string(nil), s.paths...)
+}
+
+// Add adds path last, if it is not in already.
+func (s *Selection) Add(path string) {
+	if s.Has(path) {
+		return
+	}
+	s.index[path] = len(s.paths)
+	s.paths = append(s.paths, path)
+	s.addBelow(path, 1)
+	s.numbers = nil
+}
+
+func (s *Selection) Remove(path string) {
+	i, ok := s.index[path]
+	if !ok {
+		return
+	}
+	delete(s.index, path)
+	s.addBelow(path, -1)
+	s.numbers = nil
+	s.paths = append(s.paths[:i], s.paths[i+1:]...)
+	for ; i < len(s.paths); i++ {
+		s.index[s.paths[i]] = i
+	}
+}
+
+func (s *Selection) Clear() {
+	s.paths = nil
+	s.index = make(map[string]int)
+	s.below = make(map[string]int)
+	s.numbers = nil
+}
+
+// Move moves path n places later in the order, earlier if n is negative,
+// as far as it goes.
+func (s *Selection) Move(path string, n int) {
+	i, ok := s.index[path]
+	if !ok {
+		return
+	}
+	j := i + n
+	if j < 0 {
+		j = 0
+	}
+	if j >= len(s.paths) {
+		j = len(s.paths) - 1
+	}
+
+	for i != j {
+		step := 1
+		if j < i {
+			step = -1
+		}
+		s.paths[i], s.paths[i+step] = s.paths[i+step], s.paths[i]
+		s.index[s.paths[i]] = i
+		i += step
+	}
+	s.index[path] = j
+	s.numbers = nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTestSelection(paths ...string) *Selection {
+	s := NewSelection()
+	for _, path := range paths {
+		s.Add(path)
+	}
+	return s
+}
+
+func TestSelectionAddRemove(t *testing.T) {
+	s := newTestSelection("/c", "/a", "/b", "/a")
+	if got, want := s.Paths(), []string{"/c", "/a", "/b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("added %v, want %v", got, want)
+	}
+
+	s.Remove("/a")
+	s.Remove("/missing")
+	if got, want := s.Paths(), []string{"/c", "/b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("removed to %v, want %v", got, want)
+	}
+	for i, path := range s.Paths() {
+		if j, ok := s.Index(path); !ok || j != i {
+			t.Errorf("Index(%s) = %d, %v, want %d", path, j, ok, i)
+		}
+	}
+	if s.Has("/a") {
+		t.Error("removed path is still in")
+	}
+
+	s.Clear()
+	if s.Len() != 0 || s.Has("/c") {
+		t.Errorf("cleared to %v", s.Paths())
+	}
+}
+
+func TestSelectionMove(t *testing.T) {
+	tests := []struct {
+		path string
+		n    int
+		want []string
+	}{
+		{"/a", 1, []string{"/b", "/a", "/c", "/d"}},
+		{"/a", 2, []string{"/b", "/c", "/a", "/d"}},
+		{"/a", 10, []string{"/b", "/c", "/d", "/a"}},
+		{"/d", -1, []string{"/a", "/b", "/d", "/c"}},
+		{"/c", -10, []string{"/c", "/a", "/b", "/d"}},
+		{"/b", 0, []string{"/a", "/b", "/c", "/d"}},
+		{"/missing", 1, []string{"/a", "/b", "/c", "/d"}},
+	}
+	for _, test := range tests {
+		s := newTestSelection("/a", "/b", "/c", "/d")
+		s.Move(test.path, test.n)
+		if got := s.Paths(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Move(%s, %d) = %v, want %v", test.path, test.n, got, test.want)
+		}
+		for i, path := range test.want {
+			if j, _ := s.Index(path); j != i {
+				t.Errorf("Move(%s, %d): Index(%s) = %d, want %d", test.path, test.n, path, j, i)
+			}
+		}
+	}
+}
+
+func TestSelectionSorted(t *testing.T) {
+	paths := []string{"/a/c", "/a-z", "/a/b/x", "/a/b", "/a/B"}
+	tests := []struct {
+		order SelectionOrder
+		want  []string
+	}{
+		{SelectionOrderMark, paths},
+		{SelectionOrderPath, []string{"/a-z", "/a/B", "/a/b", "/a/b/x", "/a/c"}},
+		// Directories are walked before the names after them, and names
+		// equal but for case are listed upper case first
+		{SelectionOrderListing, []string{"/a/B", "/a/b", "/a/b/x", "/a/c", "/a-z"}},
+	}
+	for _, test := range tests {
+		s := newTestSelection(paths...)
+		s.SetOrder(test.order)
+		got := s.Sorted()
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s order: %v, want %v", test.order, got, test.want)
+		}
+		for i, path := range test.want {
+			if n, ok := s.Number(path); !ok || n != i+1 {
+				t.Errorf("%s order: Number(%s) = %d, %v, want %d", test.order, path, n, ok, i+1)
+			}
+		}
+	}
+}
+
+func TestSelectionNumberUpdates(t *testing.T) {
+	s := newTestSelection("/b", "/c")
+	s.SetOrder(SelectionOrderPath)
+	if n, _ := s.Number("/b"); n != 1 {
+		t.Fatalf("Number(/b) = %d, want 1", n)
+	}
+	s.Add("/a")
+	if n, _ := s.Number("/b"); n != 2 {
+		t.Errorf("after adding /a, Number(/b) = %d, want 2", n)
+	}
+	s.Remove("/a")
+	if n, _ := s.Number("/b"); n != 1 {
+		t.Errorf("after removing /a, Number(/b) = %d, want 1", n)
+	}
+	if _, ok := s.Number("/a"); ok {
+		t.Error("removed path has a number")
+	}
+}
+
+func TestParseSelectionOrder(t *testing.T) {
+	for _, order := range []SelectionOrder{SelectionOrderMark, SelectionOrderPath, SelectionOrderListing} {
+		if got, err := ParseSelectionOrder(order.String()); err != nil || got != order {
+			t.Errorf("ParseSelectionOrder(%q) = %v, %v", order.String(), got, err)
+		}
+	}
+	if _, err := ParseSelectionOrder("size"); err == nil {
+		t.Error("ParseSelectionOrder(size) does not fail")
+	}
+}
+
+func TestSelectionBelow(t *testing.T) {
+	s := newTestSelection("/home/u/docs/a.txt", "/home/u/docs/B.txt", "/home/u/src", "/tmp/x")
+
+	below := func() map[string]int {
+		got := make(map[string]int)
+		for _, dir := range []string{"/", "/home", "/home/u", "/home/u/docs", "/home/u/src", "/tmp"} {
+			if n := s.Below(dir); n != 0 {
+				got[dir] = n
+			}
+		}
+		return got
+	}
+	want := map[string]int{"/": 4, "/home": 3, "/home/u": 3, "/home/u/docs": 2, "/tmp": 1}
+	if got := below(); !reflect.DeepEqual(got, want) {
+		t.Errorf("added: below %v, want %v", got, want)
+	}
+
+	s.Add("/home/u/src")
+	s.Remove("/home/u/docs/a.txt")
+	s.Remove("/tmp/x")
+	s.Remove("/tmp/x")
+	want = map[string]int{"/": 2, "/home": 2, "/home/u": 2, "/home/u/docs": 1}
+	if got := below(); !reflect.DeepEqual(got, want) {
+		t.Errorf("removed: below %v, want %v", got, want)
+	}
+	if _, ok := s.below["/tmp"]; ok {
+		t.Error("directories with none below are kept")
+	}
+
+	s.Clear()
+	if got := below(); len(got) != 0 {
+		t.Errorf("cleared: below %v", got)
+	}
+}
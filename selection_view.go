@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// SelectionView lists the paths marked in all directories, in order, over
+// the main and preview panes. From it, marks can be jumped to, unmarked and
+// moved in the order.
+type SelectionView struct {
+	overlayList
+
+	paths []string
+}
+
+func NewSelectionView(c *Controller, modes *Modes) *SelectionView {
+	s := &SelectionView{
+		overlayList: overlayList{
+			c:     c,
+			modes: modes,
+			mode:  "selection",
+		},
+	}
+	s.view.List.Marks = c.marks
+	s.enter = s.Jump
+
+	move := func(n int) func(int, []string) {
+		return func(count int, _ []string) {
+			s.Move(n * countOr(count, 1))
+		}
+	}
+	s.addMode(map[string]CMD{
+		"jump":      s.Jump,
+		"unmark":    s.Unmark,
+		"move_down": move(1),
+		"move_up":   move(-1),
+	}, selectionKeybindings())
+	return s
+}
+
+func selectionKeybindings() string {
+	binds := []string{
+		"ctrl-l:resize",
+		"j:next",
+		"k:prev",
+		"down:next",
+		"up:prev",
+		"ctrl-n:next",
+		"ctrl-p:prev",
+		"gg:top",
+		"G:bottom",
+		"enter:jump",
+		"l:jump",
+		"d:unmark",
+		"del:unmark",
+		"space:unmark",
+		"J:move_down",
+		"K:move_up",
+		"shift-down:move_down",
+		"shift-up:move_up",
+		"S:quit",
+		"q:quit",
+		"h:quit",
+		"esc:quit",
+		"ctrl-c:quit",
+	}
+	return strings.Join(binds, ",")
+}
+
+// Open shows the marks until closed.
+func (s *SelectionView) Open() {
+	if s.c.marks.Len() == 0 {
+		s.c.Notify("Nothing marked")
+		return
+	}
+
+	s.load()
+	s.open()
+}
+
+// load reads the marks again, with their info. Those gone since are listed
+// without.
+func (s *SelectionView) load() {
+	s.paths = s.c.marks.Sorted()
+	rows := make([]ListRow, 0, len(s.paths))
+	for _, path := range s.paths {
+		path := path
+		var info *FileInfo
+		if fi, err := s.c.fsys.Lstat(path); err == nil {
+			info = NewFileInfo(s.c.fsys, fi, filepath.Dir(path))
+		}
+
+		row := ListRow{
+			FileInfo: info,
+			Left: func(bool) ListItem {
+				contents := ListItem{}
+				contents.WriteString(path, nil)
+				return contents
+			},
+			Right: func(selected bool) ListItem {
+				contents := ListItem{}
+				if info == nil {
+					st := s.Style.Foreground(tcell.ColorRed).Reverse(selected)
+					contents.WriteString(" gone", &st)
+					return contents
+				}
+				sizeSt := s.Style.Foreground(tcell.ColorGreen).Reverse(selected)
+				contents.WriteString(fmt.Sprintf(" %4s", info.HumanizeSize()), &sizeSt)
+				timeSt := s.Style.Foreground(tcell.ColorBlue).Reverse(selected)
+				contents.WriteString(" "+info.ModTime().Format(time.ANSIC), &timeSt)
+				return contents
+			},
+		}
+		if info != nil {
+			style := StyleM.Get(info)
+			row.Style = &style
+		}
+		rows = append(rows, row)
+	}
+	s.view.List.UpdateRows(rows)
+	s.title = fmt.Sprintf("Selection (%d)", len(s.paths))
+}
+
+func (s *SelectionView) selected() (string, bool) {
+	i, ok := s.overlayList.selected(len(s.paths))
+	if !ok {
+		return "", false
+	}
+	return s.paths[i], true
+}
+
+// Jump goes to the directory of the mark selected, selecting it there.
+func (s *SelectionView) Jump() {
+	path, ok := s.selected()
+	if !ok {
+		return
+	}
+	s.Close()
+	s.c.Reveal(path)
+}
+
+func (s *SelectionView) Unmark() {
+	path, ok := s.selected()
+	if !ok {
+		return
+	}
+	s.c.marks.Remove(path)
+	s.load()
+}
+
+// Move moves the mark selected n places later in the order, earlier if n
+// is negative.
+func (s *SelectionView) Move(n int) {
+	path, ok := s.selected()
+	if !ok {
+		return
+	}
+	if order := s.c.marks.Order(); order != SelectionOrderMark {
+		s.c.Warn("Marks are output in %s order", order)
+		return
+	}
+	s.c.marks.Move(path, n)
+	s.load()
+	s.view.SelectAt, _ = s.c.marks.Index(path)
+}
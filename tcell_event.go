@@ -2,6 +2,7 @@ package main
 
 import (
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
@@ -20,8 +21,13 @@ type TcellEventHandler struct {
 func (h *TcellEventHandler) Handle(screen tcell.Screen) Event {
 	ev := screen.PollEvent()
 	switch ev := ev.(type) {
+	case *tcell.EventPaste:
+		if ev.Start() {
+			return h.readPaste(screen)
+		}
+
 	case *tcell.EventResize:
-		return Event{Resize, 0, nil}
+		return Resize.AsEvent()
 
 	// process mouse events:
 	case *tcell.EventMouse:
@@ -31,6 +37,7 @@ func (h *TcellEventHandler) Handle(screen tcell.Screen) Event {
 		// dragging has same structure, it only repeats the middle (main) event appropriately
 		x, y := ev.Position()
 		mod := ev.Modifiers() != 0
+		shift := ev.Modifiers()&tcell.ModShift != 0
 
 		// since we dont have mouse down events (unlike LightRenderer), we need to track state in prevButton
 		prevButton, button := h.prevMouseButton, ev.Buttons()
@@ -39,9 +46,9 @@ func (h *TcellEventHandler) Handle(screen tcell.Screen) Event {
 
 		switch {
 		case button&tcell.WheelDown != 0:
-			return Event{Mouse, 0, &MouseEvent{y, x, -1, false, false, false, mod}}
+			return Event{Type: Mouse, MouseEvent: &MouseEvent{y, x, -1, false, false, false, mod, shift}}
 		case button&tcell.WheelUp != 0:
-			return Event{Mouse, 0, &MouseEvent{y, x, +1, false, false, false, mod}}
+			return Event{Type: Mouse, MouseEvent: &MouseEvent{y, x, +1, false, false, false, mod, shift}}
 		case button&tcell.Button1 != 0 && !drag:
 			// all potential double click events put their 'line' coordinate in the clickY array
 			// double click event has two conditions, temporal and spatial, the first is checked here
@@ -62,9 +69,9 @@ func (h *TcellEventHandler) Handle(screen tcell.Screen) Event {
 			}
 
 			// fire single or double click event
-			return Event{Mouse, 0, &MouseEvent{y, x, 0, true, !double, double, mod}}
+			return Event{Type: Mouse, MouseEvent: &MouseEvent{y, x, 0, true, !double, double, mod, shift}}
 		case button&tcell.Button2 != 0 && !drag:
-			return Event{Mouse, 0, &MouseEvent{y, x, 0, false, true, false, mod}}
+			return Event{Type: Mouse, MouseEvent: &MouseEvent{y, x, 0, false, true, false, mod, shift}}
 		case runtime.GOOS != "windows":
 
 			// double and single taps on Windows don't quite work due to
@@ -91,7 +98,7 @@ func (h *TcellEventHandler) Handle(screen tcell.Screen) Event {
 				}
 			}
 
-			return Event{Mouse, 0, &MouseEvent{y, x, 0, left, down, double, mod}}
+			return Event{Type: Mouse, MouseEvent: &MouseEvent{y, x, 0, left, down, double, mod, shift}}
 		}
 
 		// process keyboard:
@@ -130,16 +137,16 @@ func (h *TcellEventHandler) Handle(screen tcell.Screen) Event {
 			switch ev.Rune() {
 			case 0:
 				if ctrl {
-					return Event{BSpace, 0, nil}
+					return BSpace.AsEvent()
 				}
 			case rune(tcell.KeyCtrlH):
 				switch {
 				case ctrl:
 					return keyfn('h')
 				case alt:
-					return Event{AltBS, 0, nil}
+					return AltBS.AsEvent()
 				case none, shift:
-					return Event{BSpace, 0, nil}
+					return BSpace.AsEvent()
 				}
 			}
 		case tcell.KeyCtrlI:
@@ -180,107 +187,113 @@ func (h *TcellEventHandler) Handle(screen tcell.Screen) Event {
 			return keyfn('z')
 		// section 2: Ctrl+[ \]_]
 		case tcell.KeyCtrlSpace:
-			return Event{CtrlSpace, 0, nil}
+			return CtrlSpace.AsEvent()
 		case tcell.KeyCtrlBackslash:
-			return Event{CtrlBackSlash, 0, nil}
+			return CtrlBackSlash.AsEvent()
 		case tcell.KeyCtrlRightSq:
-			return Event{CtrlRightBracket, 0, nil}
+			return CtrlRightBracket.AsEvent()
 		case tcell.KeyCtrlCarat:
-			return Event{CtrlCaret, 0, nil}
+			return CtrlCaret.AsEvent()
 		case tcell.KeyCtrlUnderscore:
-			return Event{CtrlSlash, 0, nil}
+			return CtrlSlash.AsEvent()
 		// section 3: (Alt)+Backspace2
 		case tcell.KeyBackspace2:
 			if alt {
-				return Event{AltBS, 0, nil}
+				return AltBS.AsEvent()
 			}
-			return Event{BSpace, 0, nil}
+			return BSpace.AsEvent()
 
 		// section 4: (Alt+Shift)+Key(Up|Down|Left|Right)
 		case tcell.KeyUp:
 			if altShift {
-				return Event{AltSUp, 0, nil}
+				return AltSUp.AsEvent()
 			}
 			if shift {
-				return Event{SUp, 0, nil}
+				return SUp.AsEvent()
 			}
 			if alt {
-				return Event{AltUp, 0, nil}
+				return AltUp.AsEvent()
 			}
-			return Event{Up, 0, nil}
+			return Up.AsEvent()
 		case tcell.KeyDown:
 			if altShift {
-				return Event{AltSDown, 0, nil}
+				return AltSDown.AsEvent()
 			}
 			if shift {
-				return Event{SDown, 0, nil}
+				return SDown.AsEvent()
 			}
 			if alt {
-				return Event{AltDown, 0, nil}
+				return AltDown.AsEvent()
 			}
-			return Event{Down, 0, nil}
+			return Down.AsEvent()
 		case tcell.KeyLeft:
 			if altShift {
-				return Event{AltSLeft, 0, nil}
+				return AltSLeft.AsEvent()
 			}
 			if shift {
-				return Event{SLeft, 0, nil}
+				return SLeft.AsEvent()
 			}
 			if alt {
-				return Event{AltLeft, 0, nil}
+				return AltLeft.AsEvent()
+			}
+			if ctrl {
+				return CtrlLeft.AsEvent()
 			}
-			return Event{Left, 0, nil}
+			return Left.AsEvent()
 		case tcell.KeyRight:
 			if altShift {
-				return Event{AltSRight, 0, nil}
+				return AltSRight.AsEvent()
 			}
 			if shift {
-				return Event{SRight, 0, nil}
+				return SRight.AsEvent()
 			}
 			if alt {
-				return Event{AltRight, 0, nil}
+				return AltRight.AsEvent()
 			}
-			return Event{Right, 0, nil}
+			if ctrl {
+				return CtrlRight.AsEvent()
+			}
+			return Right.AsEvent()
 
 		// section 5: (Insert|Home|Delete|End|PgUp|PgDn|BackTab|F1-F12)
 		case tcell.KeyInsert:
-			return Event{Insert, 0, nil}
+			return Insert.AsEvent()
 		case tcell.KeyHome:
-			return Event{Home, 0, nil}
+			return Home.AsEvent()
 		case tcell.KeyDelete:
-			return Event{Del, 0, nil}
+			return Del.AsEvent()
 		case tcell.KeyEnd:
-			return Event{End, 0, nil}
+			return End.AsEvent()
 		case tcell.KeyPgUp:
-			return Event{PgUp, 0, nil}
+			return PgUp.AsEvent()
 		case tcell.KeyPgDn:
-			return Event{PgDn, 0, nil}
+			return PgDn.AsEvent()
 		case tcell.KeyBacktab:
-			return Event{BTab, 0, nil}
+			return BTab.AsEvent()
 		case tcell.KeyF1:
-			return Event{F1, 0, nil}
+			return F1.AsEvent()
 		case tcell.KeyF2:
-			return Event{F2, 0, nil}
+			return F2.AsEvent()
 		case tcell.KeyF3:
-			return Event{F3, 0, nil}
+			return F3.AsEvent()
 		case tcell.KeyF4:
-			return Event{F4, 0, nil}
+			return F4.AsEvent()
 		case tcell.KeyF5:
-			return Event{F5, 0, nil}
+			return F5.AsEvent()
 		case tcell.KeyF6:
-			return Event{F6, 0, nil}
+			return F6.AsEvent()
 		case tcell.KeyF7:
-			return Event{F7, 0, nil}
+			return F7.AsEvent()
 		case tcell.KeyF8:
-			return Event{F8, 0, nil}
+			return F8.AsEvent()
 		case tcell.KeyF9:
-			return Event{F9, 0, nil}
+			return F9.AsEvent()
 		case tcell.KeyF10:
-			return Event{F10, 0, nil}
+			return F10.AsEvent()
 		case tcell.KeyF11:
-			return Event{F11, 0, nil}
+			return F11.AsEvent()
 		case tcell.KeyF12:
-			return Event{F12, 0, nil}
+			return F12.AsEvent()
 
 		// section 6: (Ctrl+Alt)+'rune'
 		case tcell.KeyRune:
@@ -289,23 +302,48 @@ func (h *TcellEventHandler) Handle(screen tcell.Screen) Event {
 			switch {
 			// translate native key events to ascii control characters
 			case r == ' ' && ctrl:
-				return Event{CtrlSpace, 0, nil}
+				return CtrlSpace.AsEvent()
 			// handle AltGr characters
 			case ctrlAlt:
-				return Event{Rune, r, nil} // dropping modifiers
+				return Key(r) // dropping modifiers
 			// simple characters (possibly with modifier)
 			case alt:
 				return AltKey(r)
 			default:
-				return Event{Rune, r, nil}
+				return Key(r)
 			}
 
 		// section 7: Esc
 		case tcell.KeyEsc:
-			return Event{ESC, 0, nil}
+			return ESC.AsEvent()
 		}
 	}
 
 	// section 8: Invalid
-	return Event{Invalid, 0, nil}
+	return Invalid.AsEvent()
+}
+
+// readPaste collects the keys of a bracketed paste, up to its end, into a
+// single Paste event.
+func (h *TcellEventHandler) readPaste(screen tcell.Screen) Event {
+	var sb strings.Builder
+	for {
+		switch ev := screen.PollEvent().(type) {
+		case nil:
+			return Event{Type: Paste, Text: sb.String()}
+		case *tcell.EventPaste:
+			if ev.End() {
+				return Event{Type: Paste, Text: sb.String()}
+			}
+		case *tcell.EventKey:
+			switch ev.Key() {
+			case tcell.KeyRune:
+				sb.WriteRune(ev.Rune())
+			case tcell.KeyEnter, tcell.KeyCtrlJ:
+				sb.WriteByte('\n')
+			case tcell.KeyTab:
+				sb.WriteByte('\t')
+			}
+		}
+	}
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/djherbis/times"
+)
+
+// FS is what Dirs are listed from and the Controller navigates in. It is
+// modelled on io/fs, except that names are absolute paths as shown to the
+// user, and it also knows about links and ownership.
+type FS interface {
+	Open(name string) (fs.File, error)
+	// ReadDir lists a directory without following symlinks in it.
+	ReadDir(name string) ([]fs.FileInfo, error)
+	Stat(name string) (fs.FileInfo, error)
+	Lstat(name string) (fs.FileInfo, error)
+	Readlink(name string) (string, error)
+	// Attr returns what fs.FileInfo does not carry portably for an info
+	// returned by this FS.
+	Attr(info fs.FileInfo) FileAttr
+	// Chdir is called when navigating to the directory name.
+	Chdir(name string) error
+}
+
+// FileAttr holds ownership, link count and times of a file. User and Group
+// are names if the FS has them, Uid and Gid are ids that are looked up
+// otherwise. Zero values are shown as unknown.
+type FileAttr struct {
+	Uid, Gid    string
+	User, Group string
+	Links       uint64
+	AccessTime  time.Time
+	ChangeTime  time.Time
+}
+
+// readHead reads up to n bytes from the start of the file name.
+func readHead(fsys FS, name string, n int64) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, n))
+}
+
+// OSFS is the local file system.
+type OSFS struct{}
+
+func (OSFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (OSFS) ReadDir(name string) ([]fs.FileInfo, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return f.Readdir(-1)
+}
+
+func (OSFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (OSFS) Lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (OSFS) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+func (OSFS) Attr(info fs.FileInfo) FileAttr {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return FileAttr{
+			AccessTime: info.ModTime(),
+			ChangeTime: info.ModTime(),
+		}
+	}
+
+	attr := FileAttr{
+		Uid:   fmt.Sprint(stat.Uid),
+		Gid:   fmt.Sprint(stat.Gid),
+		Links: uint64(stat.Nlink),
+	}
+
+	ts := times.Get(info)
+	attr.AccessTime = ts.AccessTime()
+	// from times docs: ChangeTime() panics unless HasChangeTime() is true
+	if ts.HasChangeTime() {
+		attr.ChangeTime = ts.ChangeTime()
+	} else {
+		// fall back to ModTime if ChangeTime cannot be determined
+		attr.ChangeTime = info.ModTime()
+	}
+	return attr
+}
+
+func (OSFS) Chdir(name string) error {
+	return os.Chdir(name)
+}
+
+// LocalFS is the local file system with archives mounted as directories.
+type LocalFS struct {
+	OSFS
+	archives ArchiveFS
+}
+
+// route returns the FS for name. Archives themselves are files, only
+// listing them or going into them is left to the ArchiveFS.
+func (l LocalFS) route(name string, asDir bool) FS {
+	if _, member, ok := splitArchivePath(name); ok && (asDir || member != "") {
+		return l.archives
+	}
+	return l.OSFS
+}
+
+func (l LocalFS) Open(name string) (fs.File, error) {
+	return l.route(name, false).Open(name)
+}
+
+func (l LocalFS) ReadDir(name string) ([]fs.FileInfo, error) {
+	return l.route(name, true).ReadDir(name)
+}
+
+func (l LocalFS) Stat(name string) (fs.FileInfo, error) {
+	return l.route(name, false).Stat(name)
+}
+
+func (l LocalFS) Lstat(name string) (fs.FileInfo, error) {
+	return l.route(name, false).Lstat(name)
+}
+
+func (l LocalFS) Readlink(name string) (string, error) {
+	return l.route(name, false).Readlink(name)
+}
+
+func (l LocalFS) Attr(info fs.FileInfo) FileAttr {
+	if _, ok := info.Sys().(*syscall.Stat_t); ok {
+		return l.OSFS.Attr(info)
+	}
+	return l.archives.Attr(info)
+}
+
+func (l LocalFS) Chdir(name string) error {
+	return l.route(name, true).Chdir(name)
+}